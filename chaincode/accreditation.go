@@ -0,0 +1,239 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AccreditationInput is an accreditation decision of an accrediting body.
+// DocumentHash is the hex sha256 of the decision document
+type AccreditationInput struct {
+	OrgID           string   `json:"orgId" validate:"required,id"`
+	AccreditingBody string   `json:"accreditingBody" validate:"required,max=256"`
+	EducationLevels []string `json:"educationLevels,omitempty" metadata:",optional" validate:"max=32,dive,required,max=64"`
+	FieldCodes      []string `json:"fieldCodes" validate:"required,min=1,max=256,dive,required,max=64"`
+	ValidFrom       int64    `json:"validFrom" validate:"gt=0"`
+	ValidTo         int64    `json:"validTo" validate:"gtfield=ValidFrom"`
+	DocumentHash    string   `json:"documentHash" validate:"required,sha256hex"`
+}
+
+type Accreditation struct {
+	DocType           string   `json:"docType"`
+	ID                string   `json:"id"`
+	OrgID             string   `json:"orgId"`
+	AccreditingBody   string   `json:"accreditingBody"`
+	EducationLevels   []string `json:"educationLevels"`
+	FieldCodes        []string `json:"fieldCodes"`
+	ValidFrom         int64    `json:"validFrom"`
+	ValidTo           int64    `json:"validTo"`
+	DocumentHash      string   `json:"documentHash"`
+	RevokedAt         int64    `json:"revokedAt,omitempty" metadata:",optional"`
+	Reason            string   `json:"reason,omitempty" metadata:",optional"`
+	RevokedBy         Actor    `json:"revokedBy" metadata:",optional"`
+	CreatedBy         Actor    `json:"createdBy"`
+	CreateTxTimestamp int64    `json:"createTxTimestamp"`
+	UpdateTxTimestamp int64    `json:"updateTxTimestamp"`
+}
+
+// coversAt tells whether the accreditation was in force at atTime, a
+// revocation does not change the past
+func (a *Accreditation) coversAt(atTime int64) bool {
+	if atTime < a.ValidFrom || atTime > a.ValidTo {
+		return false
+	}
+	return a.RevokedAt == 0 || atTime < a.RevokedAt
+}
+
+// AddAccreditation attaches an accreditation to an org, it is kept by the
+// accreditation authority
+func (s *SmartContract) AddAccreditation(ctx contractapi.TransactionContextInterface, input AccreditationInput) (*Accreditation, error) {
+	input.DocumentHash = strings.ToLower(input.DocumentHash)
+	if err := validateInput(&input); err != nil {
+		return nil, err
+	}
+	orgExists, err := s.OrgExists(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if !orgExists {
+		return nil, newNotFoundError("Org %s does not exist", input.OrgID)
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	educationLevels := input.EducationLevels
+	if educationLevels == nil {
+		educationLevels = []string{}
+	}
+	accreditation := &Accreditation{
+		DocType:           "Accreditation",
+		ID:                ctx.GetStub().GetTxID(),
+		OrgID:             input.OrgID,
+		AccreditingBody:   input.AccreditingBody,
+		EducationLevels:   educationLevels,
+		FieldCodes:        input.FieldCodes,
+		ValidFrom:         input.ValidFrom,
+		ValidTo:           input.ValidTo,
+		DocumentHash:      input.DocumentHash,
+		CreatedBy:         actor,
+		CreateTxTimestamp: ts.AsTime().Unix(),
+		UpdateTxTimestamp: ts.AsTime().Unix(),
+	}
+	if err = s.putAccreditation(ctx.GetStub(), accreditation); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.AccreditationAdded, newAccreditationEvent(accreditation)); err != nil {
+		return nil, err
+	}
+	return accreditation, nil
+}
+
+// RevokeAccreditation ends an accreditation at the transaction time
+func (s *SmartContract) RevokeAccreditation(ctx contractapi.TransactionContextInterface, orgId string, id string, reason string) (*Accreditation, error) {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return nil, err
+	}
+	if err := validateInput(&referenceInput{ID: id, Reason: reason}); err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		return nil, newValidationError("reason is required")
+	}
+	accreditation, err := s.readAccreditation(ctx.GetStub(), orgId, id)
+	if err != nil {
+		return nil, err
+	}
+	if accreditation.RevokedAt > 0 {
+		return nil, newConflictError("Accreditation %s is already revoked", id)
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	accreditation.RevokedAt = ts.AsTime().Unix()
+	accreditation.Reason = reason
+	accreditation.RevokedBy = actor
+	accreditation.UpdateTxTimestamp = ts.AsTime().Unix()
+	if err = s.putAccreditation(ctx.GetStub(), accreditation); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.AccreditationRevoked, newAccreditationEvent(accreditation)); err != nil {
+		return nil, err
+	}
+	return accreditation, nil
+}
+
+// ListOrgAccreditations lists every accreditation of an org, revoked and
+// expired ones included
+func (s *SmartContract) ListOrgAccreditations(ctx contractapi.TransactionContextInterface, orgId string) ([]*Accreditation, error) {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return nil, err
+	}
+	return s.orgAccreditations(ctx.GetStub(), orgId)
+}
+
+// IsOrgAccreditedFor tells whether an org held an accreditation covering
+// fieldCode at atTime, e.g. the date of a diploma
+func (s *SmartContract) IsOrgAccreditedFor(ctx contractapi.TransactionContextInterface, orgId string, fieldCode string, atTime int64) (bool, error) {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return false, err
+	}
+	if fieldCode == "" {
+		return false, newValidationError("fieldCode is required")
+	}
+	accreditations, err := s.orgAccreditations(ctx.GetStub(), orgId)
+	if err != nil {
+		return false, err
+	}
+	for _, accreditation := range accreditations {
+		if accreditation.coversAt(atTime) && containsString(accreditation.FieldCodes, fieldCode) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *SmartContract) orgAccreditations(stub shim.ChaincodeStubInterface, orgId string) ([]*Accreditation, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("Accreditation", []string{orgId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	accreditations := make([]*Accreditation, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var accreditation Accreditation
+		if err = json.Unmarshal(queryResult.Value, &accreditation); err != nil {
+			return nil, err
+		}
+		accreditations = append(accreditations, &accreditation)
+	}
+	return accreditations, nil
+}
+
+func (s *SmartContract) readAccreditation(stub shim.ChaincodeStubInterface, orgId string, id string) (*Accreditation, error) {
+	stateId, err := s.newAccreditationStateId(stub, orgId, id)
+	if err != nil {
+		return nil, err
+	}
+	accreditationJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	if accreditationJSON == nil {
+		return nil, newNotFoundError("Accreditation %s of org %s does not exist", id, orgId)
+	}
+	var accreditation Accreditation
+	if err = json.Unmarshal(accreditationJSON, &accreditation); err != nil {
+		return nil, err
+	}
+	return &accreditation, nil
+}
+
+func (s *SmartContract) putAccreditation(stub shim.ChaincodeStubInterface, accreditation *Accreditation) error {
+	stateId, err := s.newAccreditationStateId(stub, accreditation.OrgID, accreditation.ID)
+	if err != nil {
+		return err
+	}
+	accreditationJSON, err := json.Marshal(accreditation)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(stateId, accreditationJSON)
+}
+
+func newAccreditationEvent(accreditation *Accreditation) events.Accreditation {
+	event := events.Accreditation{
+		ID:              accreditation.ID,
+		OrgID:           accreditation.OrgID,
+		AccreditingBody: accreditation.AccreditingBody,
+		EducationLevels: accreditation.EducationLevels,
+		FieldCodes:      accreditation.FieldCodes,
+		ValidFrom:       accreditation.ValidFrom,
+		ValidTo:         accreditation.ValidTo,
+		DocumentHash:    accreditation.DocumentHash,
+		RevokedAt:       accreditation.RevokedAt,
+		Reason:          accreditation.Reason,
+	}
+	if accreditation.RevokedAt > 0 {
+		event.RevokedBy = &events.Actor{MSPID: accreditation.RevokedBy.MSPID, ID: accreditation.RevokedBy.ID}
+	}
+	return event
+}
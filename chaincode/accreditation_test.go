@@ -0,0 +1,42 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeAccreditationRecordsActor(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG1")
+	accreditation, err := sc.AddAccreditation(l.as(su), chaincode.AccreditationInput{
+		OrgID:           "ORG1",
+		AccreditingBody: "MNCEA",
+		FieldCodes:      []string{"0613"},
+		ValidFrom:       l.now.Unix(),
+		ValidTo:         l.now.Unix() + 3600,
+		DocumentHash:    documentHash,
+	})
+	require.NoError(t, err)
+
+	revoker := newSuperAdmin(t, "revoker")
+	accreditation, err = sc.RevokeAccreditation(l.as(revoker), "ORG1", accreditation.ID, "withdrawn")
+	require.NoError(t, err)
+	require.Equal(t, "DsolutionsOrgMSP", accreditation.RevokedBy.MSPID)
+	require.NotEmpty(t, accreditation.RevokedBy.ID)
+	require.NotEqual(t, accreditation.CreatedBy.ID, accreditation.RevokedBy.ID)
+
+	_, payload := l.stub.SetEventArgsForCall(l.stub.SetEventCallCount() - 1)
+	var envelope events.Envelope
+	require.NoError(t, json.Unmarshal(payload, &envelope))
+	require.Equal(t, events.AccreditationRevoked, envelope.Events[len(envelope.Events)-1].Type)
+	var event events.Accreditation
+	require.NoError(t, envelope.Events[len(envelope.Events)-1].Decode(&event))
+	require.NotNil(t, event.RevokedBy)
+	require.Equal(t, accreditation.RevokedBy.ID, event.RevokedBy.ID)
+}
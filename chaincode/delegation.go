@@ -0,0 +1,248 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// OrgRoleDelegation lets an org admin hand a role to another identity until ExpiresAt
+type OrgRoleDelegation struct {
+	DocType               string `json:"docType"`
+	ID                    string `json:"id"`
+	OrgID                 string `json:"orgId"`
+	Role                  string `json:"role"`
+	GranteeMSPID          string `json:"granteeMspId"`
+	GranteeIdentityHash   string `json:"granteeIdentityHash"`
+	DelegatorMSPID        string `json:"delegatorMspId"`
+	DelegatorIdentityHash string `json:"delegatorIdentityHash"`
+	ExpiresAt             int64  `json:"expiresAt"`
+	Revoked               bool   `json:"revoked"`
+	UpdatedBy             Actor  `json:"updatedBy" metadata:",optional"`
+	CreateTxTimestamp     int64  `json:"createTxTimestamp"`
+	UpdateTxTimestamp     int64  `json:"updateTxTimestamp"`
+}
+
+func (s *SmartContract) DelegateOrgRole(ctx contractapi.TransactionContextInterface, orgId string, granteeMspId string, granteeIdentityHash string, role string, expiresAt int64) (*OrgRoleDelegation, error) {
+	if err := validateInput(&orgRoleDelegationInput{OrgID: orgId, GranteeMSPID: granteeMspId, GranteeIdentityHash: granteeIdentityHash, Role: role, ExpiresAt: expiresAt}); err != nil {
+		return nil, err
+	}
+	exists, err := s.OrgExists(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, newNotFoundError("Org %s does not exist", orgId)
+	}
+	// delegated roles can not be delegated further
+	if s.IsIdentitySuperAdmin(ctx) != nil {
+		roles, err := s.identityDelegableOrgRoles(ctx, orgId)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(roles, role) {
+			return nil, newPermissionDeniedError("Insufficient Role Permission - can not delegate role %s", role)
+		}
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt <= ts.AsTime().Unix() {
+		return nil, newValidationError("expiresAt should be in the future")
+	}
+	mspId, clientId, err := getClientIdentity(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	delegation := &OrgRoleDelegation{
+		DocType:               "OrgRoleDelegation",
+		ID:                    ctx.GetStub().GetTxID(),
+		OrgID:                 orgId,
+		Role:                  role,
+		GranteeMSPID:          granteeMspId,
+		GranteeIdentityHash:   granteeIdentityHash,
+		DelegatorMSPID:        mspId,
+		DelegatorIdentityHash: identityHash(clientId),
+		ExpiresAt:             expiresAt,
+		UpdatedBy:             actor,
+		CreateTxTimestamp:     ts.AsTime().Unix(),
+		UpdateTxTimestamp:     ts.AsTime().Unix(),
+	}
+	if err = s.putOrgRoleDelegation(ctx.GetStub(), delegation); err != nil {
+		return nil, err
+	}
+	indexId, err := s.newOrgRoleDelegationGranteeIndexId(ctx.GetStub(), orgId, granteeMspId, granteeIdentityHash, delegation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.GetStub().PutState(indexId, []byte{0x00}); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.OrgRoleDelegated, newOrgRoleDelegationEvent(delegation)); err != nil {
+		return nil, err
+	}
+	return delegation, nil
+}
+
+// RevokeOrgRoleDelegation can be called by the delegating admin or a super admin
+func (s *SmartContract) RevokeOrgRoleDelegation(ctx contractapi.TransactionContextInterface, orgId string, id string) error {
+	if err := validateInput(&referenceInput{ID: id}); err != nil {
+		return err
+	}
+	delegation, err := s.readOrgRoleDelegation(ctx.GetStub(), id)
+	if err != nil {
+		return err
+	}
+	if delegation.OrgID != orgId {
+		return newNotFoundError("Delegation %s does not belong to org %s", id, orgId)
+	}
+	if delegation.Revoked {
+		return newConflictError("Delegation %s is already revoked", id)
+	}
+	if s.IsIdentitySuperAdmin(ctx) != nil {
+		mspId, clientId, err := getClientIdentity(ctx.GetStub())
+		if err != nil {
+			return err
+		}
+		if delegation.DelegatorMSPID != mspId || delegation.DelegatorIdentityHash != identityHash(clientId) {
+			return newPermissionDeniedError("Insufficient Permission - only the delegating identity can revoke")
+		}
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	delegation.Revoked = true
+	delegation.UpdatedBy = actor
+	delegation.UpdateTxTimestamp = ts.AsTime().Unix()
+	if err = s.putOrgRoleDelegation(ctx.GetStub(), delegation); err != nil {
+		return err
+	}
+	indexId, err := s.newOrgRoleDelegationGranteeIndexId(ctx.GetStub(), delegation.OrgID, delegation.GranteeMSPID, delegation.GranteeIdentityHash, delegation.ID)
+	if err != nil {
+		return err
+	}
+	if err = ctx.GetStub().DelState(indexId); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, events.OrgRoleDelegationRevoked, newOrgRoleDelegationEvent(delegation))
+}
+
+// ListOrgRoleDelegations lists the delegations of an org that have neither
+// been revoked nor expired
+func (s *SmartContract) ListOrgRoleDelegations(ctx contractapi.TransactionContextInterface, orgId string) ([]*OrgRoleDelegation, error) {
+	delegations, err := s.indexedOrgRoleDelegations(ctx.GetStub(), []string{orgId})
+	if err != nil {
+		return nil, err
+	}
+	return activeOrgRoleDelegations(ctx.GetStub(), delegations)
+}
+
+// identityOrgRoleDelegations returns the unexpired delegations granted to an identity on an org
+func (s *SmartContract) identityOrgRoleDelegations(stub shim.ChaincodeStubInterface, orgId string, mspId string, identityHash string) ([]*OrgRoleDelegation, error) {
+	delegations, err := s.indexedOrgRoleDelegations(stub, []string{orgId, mspId, identityHash})
+	if err != nil {
+		return nil, err
+	}
+	return activeOrgRoleDelegations(stub, delegations)
+}
+
+// indexedOrgRoleDelegations returns the delegations in the grantee index,
+// expired ones included, for a partial key starting with the org id
+func (s *SmartContract) indexedOrgRoleDelegations(stub shim.ChaincodeStubInterface, keys []string) ([]*OrgRoleDelegation, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("OrgRoleDelegation~grantee", keys)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	return s.constructDelegationsFromIndexIterator(stub, resultsIterator)
+}
+
+// activeOrgRoleDelegations keeps the delegations that are neither revoked nor
+// expired at the transaction time
+func activeOrgRoleDelegations(stub shim.ChaincodeStubInterface, delegations []*OrgRoleDelegation) ([]*OrgRoleDelegation, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	active := make([]*OrgRoleDelegation, 0, len(delegations))
+	for _, delegation := range delegations {
+		if !delegation.Revoked && ts.AsTime().Unix() < delegation.ExpiresAt {
+			active = append(active, delegation)
+		}
+	}
+	return active, nil
+}
+
+func (s *SmartContract) constructDelegationsFromIndexIterator(stub shim.ChaincodeStubInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*OrgRoleDelegation, error) {
+	var delegations []*OrgRoleDelegation = make([]*OrgRoleDelegation, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+		delegation, err := s.readOrgRoleDelegation(stub, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		delegations = append(delegations, delegation)
+	}
+	return delegations, nil
+}
+
+func (s *SmartContract) readOrgRoleDelegation(stub shim.ChaincodeStubInterface, id string) (*OrgRoleDelegation, error) {
+	stateId, err := s.newOrgRoleDelegationStateId(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	delegationJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	if delegationJSON == nil {
+		return nil, newNotFoundError("Delegation %s does not exist", id)
+	}
+	var delegation OrgRoleDelegation
+	if err = json.Unmarshal(delegationJSON, &delegation); err != nil {
+		return nil, err
+	}
+	return &delegation, nil
+}
+
+func (s *SmartContract) putOrgRoleDelegation(stub shim.ChaincodeStubInterface, delegation *OrgRoleDelegation) error {
+	stateId, err := s.newOrgRoleDelegationStateId(stub, delegation.ID)
+	if err != nil {
+		return err
+	}
+	delegationJSON, err := json.Marshal(delegation)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(stateId, delegationJSON)
+}
+
+func newOrgRoleDelegationEvent(delegation *OrgRoleDelegation) events.OrgRoleDelegation {
+	return events.OrgRoleDelegation{
+		ID:                  delegation.ID,
+		OrgID:               delegation.OrgID,
+		Role:                delegation.Role,
+		GranteeMSPID:        delegation.GranteeMSPID,
+		GranteeIdentityHash: delegation.GranteeIdentityHash,
+		ExpiresAt:           delegation.ExpiresAt,
+	}
+}
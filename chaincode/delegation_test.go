@@ -0,0 +1,50 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+const granteeIdentityHash = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+
+func TestDelegateOrgRoleAsParentAdmin(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "PARENT")
+	createOrg(t, l, su, "OTHER")
+	_, err := sc.CreateOrgWithInput(l.as(su), chaincode.CreateOrgInput{OrgID: "CHILD", Name: "CHILD", ParentOrgID: "PARENT"})
+	require.NoError(t, err)
+	expiresAt := l.now.Unix() + 3600
+
+	delegation, err := sc.DelegateOrgRole(l.as(newOrgAdmin(t, "PARENT")), "CHILD", "Org1MSP", granteeIdentityHash, "admin", expiresAt)
+	require.NoError(t, err)
+	require.Equal(t, "CHILD", delegation.OrgID)
+
+	_, err = sc.DelegateOrgRole(l.as(newOrgAdmin(t, "OTHER")), "CHILD", "Org1MSP", granteeIdentityHash, "admin", expiresAt)
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, err)
+}
+
+func TestExpiredOrgRoleDelegations(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG1")
+	delegation, err := sc.DelegateOrgRole(l.as(su), "ORG1", "Org1MSP", granteeIdentityHash, "admin", l.now.Unix()+60)
+	require.NoError(t, err)
+
+	delegations, err := sc.ListOrgRoleDelegations(l.as(su), "ORG1")
+	require.NoError(t, err)
+	require.Len(t, delegations, 1)
+	requireErrorCode(t, chaincode.ErrorCodeConflict, sc.PurgeOrg(l.as(su), "ORG1"))
+
+	l.now = time.Unix(delegation.ExpiresAt, 0)
+	delegations, err = sc.ListOrgRoleDelegations(l.as(su), "ORG1")
+	require.NoError(t, err)
+	require.Empty(t, delegations)
+	require.NoError(t, sc.PurgeOrg(l.as(su), "ORG1"))
+	require.Empty(t, l.keysWithPrefix("\x00OrgRoleDelegation", "", 0))
+}
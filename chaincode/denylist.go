@@ -0,0 +1,200 @@
+package chaincode
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	DenyKindID     = "id"
+	DenyKindSerial = "serial"
+)
+
+// DeniedIdentity blocks a certificate before the CRL reaches the channel config.
+// Value is the certificate ID (cid.GetID) for kind id and the hex serial number
+// for kind serial. Serial numbers are only unique per CA, so serial entries are
+// scoped by Issuer, the hex authority key identifier of the certificate
+type DeniedIdentity struct {
+	DocType     string `json:"docType"`
+	Kind        string `json:"kind"`
+	Issuer      string `json:"issuer" metadata:",optional"`
+	Value       string `json:"value"`
+	Reason      string `json:"reason"`
+	UpdatedBy   Actor  `json:"updatedBy" metadata:",optional"`
+	TxTimestamp int64  `json:"txTimestamp"`
+}
+
+// DenyIdentity blocks an identity. Serials and authority key identifiers are
+// hex, optionally separated by colons as printed by openssl
+func (s *SmartContract) DenyIdentity(ctx contractapi.TransactionContextInterface, kind string, issuer string, value string, reason string) (*DeniedIdentity, error) {
+	if err := validateInput(&deniedIdentityInput{Kind: kind, Issuer: issuer, Value: value, Reason: reason}); err != nil {
+		return nil, err
+	}
+	if kind == DenyKindSerial && issuer == "" {
+		return nil, newValidationError("issuer is required for kind %s", DenyKindSerial)
+	}
+	issuer, value, err := normalizeDeniedValue(kind, issuer, value)
+	if err != nil {
+		return nil, err
+	}
+	stateId, err := s.newDeniedIdentityStateId(ctx.GetStub(), kind, issuer, value)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	denied := &DeniedIdentity{
+		DocType:     "DeniedIdentity",
+		Kind:        kind,
+		Issuer:      issuer,
+		Value:       value,
+		Reason:      reason,
+		UpdatedBy:   actor,
+		TxTimestamp: ts.AsTime().Unix(),
+	}
+	deniedJSON, err := json.Marshal(denied)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.GetStub().PutState(stateId, deniedJSON); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.IdentityDenied, events.DeniedIdentity{Kind: kind, Issuer: issuer, Value: value, Reason: reason}); err != nil {
+		return nil, err
+	}
+	return denied, nil
+}
+
+// AllowIdentity removes a deny-list entry. An empty issuer removes a serial
+// entry written before entries were scoped by issuer
+func (s *SmartContract) AllowIdentity(ctx contractapi.TransactionContextInterface, kind string, issuer string, value string) error {
+	if err := validateInput(&deniedIdentityInput{Kind: kind, Issuer: issuer, Value: value}); err != nil {
+		return err
+	}
+	issuer, value, err := normalizeDeniedValue(kind, issuer, value)
+	if err != nil {
+		return err
+	}
+	stateId, err := s.newDeniedIdentityStateId(ctx.GetStub(), kind, issuer, value)
+	if err != nil {
+		return err
+	}
+	deniedJSON, err := ctx.GetStub().GetState(stateId)
+	if err != nil {
+		return err
+	}
+	if deniedJSON == nil {
+		return newNotFoundError("Identity %s %s is not denied", kind, value)
+	}
+	if err = ctx.GetStub().DelState(stateId); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, events.IdentityAllowed, events.DeniedIdentity{Kind: kind, Issuer: issuer, Value: value})
+}
+
+func (s *SmartContract) ListDeniedIdentities(ctx contractapi.TransactionContextInterface) ([]*DeniedIdentity, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("DeniedIdentity", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var denied []*DeniedIdentity = make([]*DeniedIdentity, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var item DeniedIdentity
+		if err = json.Unmarshal(queryResult.Value, &item); err != nil {
+			return nil, err
+		}
+		denied = append(denied, &item)
+	}
+	return denied, nil
+}
+
+// normalizeDeniedValue returns the canonical issuer and value of an entry.
+// Serials are compared as numbers, so 0a:1b and a1b are the same serial
+func normalizeDeniedValue(kind string, issuer string, value string) (string, string, error) {
+	switch kind {
+	case DenyKindID:
+		if issuer != "" {
+			return "", "", newValidationError("issuer is part of the value for kind %s", DenyKindID)
+		}
+		if value == "" {
+			return "", "", newValidationError("value is required")
+		}
+		return "", value, nil
+	case DenyKindSerial:
+		serial, ok := new(big.Int).SetString(strings.ReplaceAll(value, ":", ""), 16)
+		if !ok || serial.Sign() < 0 {
+			return "", "", newValidationError("value should be a hex serial number")
+		}
+		if issuer == "" {
+			return "", serial.Text(16), nil
+		}
+		keyId, err := hex.DecodeString(strings.ReplaceAll(issuer, ":", ""))
+		if err != nil || len(keyId) == 0 {
+			return "", "", newValidationError("issuer should be a hex authority key identifier")
+		}
+		return hex.EncodeToString(keyId), serial.Text(16), nil
+	default:
+		return "", "", newValidationError("kind should be either of %s or %s", DenyKindID, DenyKindSerial)
+	}
+}
+
+// deniedCandidate is a deny-list key that matches the submitting certificate
+type deniedCandidate struct {
+	kind   string
+	issuer string
+	value  string
+}
+
+// assertIdentityNotDenied rejects submitters whose certificate is on the deny-list.
+// Unscoped serial entries written before entries carried an issuer still match
+func (s *SmartContract) assertIdentityNotDenied(stub shim.ChaincodeStubInterface) error {
+	clientId, err := cid.GetID(stub)
+	if err != nil {
+		return newInternalError("Error when retrieving client id")
+	}
+	cert, err := cid.GetX509Certificate(stub)
+	if err != nil {
+		return newInternalError("Error when retrieving client certificate")
+	}
+	candidates := []deniedCandidate{{kind: DenyKindID, value: clientId}}
+	if cert != nil && cert.SerialNumber != nil {
+		serial := cert.SerialNumber.Text(16)
+		if len(cert.AuthorityKeyId) > 0 {
+			candidates = append(candidates, deniedCandidate{kind: DenyKindSerial, issuer: hex.EncodeToString(cert.AuthorityKeyId), value: serial})
+		}
+		candidates = append(candidates, deniedCandidate{kind: DenyKindSerial, value: serial})
+	}
+	for _, candidate := range candidates {
+		stateId, err := s.newDeniedIdentityStateId(stub, candidate.kind, candidate.issuer, candidate.value)
+		if err != nil {
+			return err
+		}
+		deniedJSON, err := stub.GetState(stateId)
+		if err != nil {
+			return err
+		}
+		if deniedJSON != nil {
+			return newPermissionDeniedError("Insufficient Permission - identity is denied")
+		}
+	}
+	return nil
+}
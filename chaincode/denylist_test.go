@@ -0,0 +1,71 @@
+package chaincode_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func newSerialIdentity(t *testing.T, serial int64, authorityKeyId []byte) []byte {
+	return newCertificateIdentity(t, "Org1MSP", &x509.Certificate{
+		SerialNumber:   big.NewInt(serial),
+		Subject:        pkix.Name{CommonName: "user"},
+		AuthorityKeyId: authorityKeyId,
+	})
+}
+
+func TestDenySerialMatchesCanonicalForm(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	caKeyId := []byte{0xab, 0x01, 0xcd}
+	user := newSerialIdentity(t, 0x0a1b, caKeyId)
+	require.NoError(t, l.call(user, "ReadOrg", "ORG1"))
+
+	denied, err := sc.DenyIdentity(l.as(su), chaincode.DenyKindSerial, "AB:01:CD", "00:0a:1b", "lost")
+	require.NoError(t, err)
+	require.Equal(t, "ab01cd", denied.Issuer)
+	require.Equal(t, "a1b", denied.Value)
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, l.call(user, "ReadOrg", "ORG1"))
+
+	// the same serial from another CA is a different certificate
+	require.NoError(t, l.call(newSerialIdentity(t, 0x0a1b, []byte{0x01}), "ReadOrg", "ORG1"))
+	require.NoError(t, l.call(newSerialIdentity(t, 0x0a1c, caKeyId), "ReadOrg", "ORG1"))
+
+	require.NoError(t, sc.AllowIdentity(l.as(su), chaincode.DenyKindSerial, "ab01cd", "a1b"))
+	require.NoError(t, l.call(user, "ReadOrg", "ORG1"))
+}
+
+func TestDenyIdentityValidation(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+
+	_, err := sc.DenyIdentity(l.as(su), chaincode.DenyKindSerial, "", "0a1b", "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	_, err = sc.DenyIdentity(l.as(su), chaincode.DenyKindSerial, "ab01", "0x0a1b", "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	_, err = sc.DenyIdentity(l.as(su), chaincode.DenyKindSerial, "not-hex", "0a1b", "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	_, err = sc.DenyIdentity(l.as(su), chaincode.DenyKindID, "ab01", "x509::CN=user::CN=ca", "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+}
+
+func TestDenyIdentityByID(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	user := newIdentity(t, "Org1MSP", "user", nil)
+	l.as(user)
+	identity, err := sc.GetMyIdentity(l.ctx)
+	require.NoError(t, err)
+
+	_, err = sc.DenyIdentity(l.as(su), chaincode.DenyKindID, "", identity.ClientID, "")
+	require.NoError(t, err)
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, l.call(user, "ReadOrg", "ORG1"))
+	require.NoError(t, l.call(newIdentity(t, "Org1MSP", "other", nil), "ReadOrg", "ORG1"))
+}
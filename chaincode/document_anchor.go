@@ -0,0 +1,172 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// documentAnchorFee is the credit spent by the org for every anchored hash
+const documentAnchorFee = "1"
+
+// DocumentAnchor proves that OrgID issued a document with Hash, the hex sha256
+// of the document, at TxTimestamp. KeyID is the fingerprint of the org key at
+// that time
+type DocumentAnchor struct {
+	DocType      string `json:"docType"`
+	Hash         string `json:"hash"`
+	OrgID        string `json:"orgId"`
+	DocumentType string `json:"documentType"`
+	Metadata     string `json:"metadata"`
+	KeyID        string `json:"keyId"`
+	AnchoredBy   Actor  `json:"anchoredBy"`
+	TxID         string `json:"txId"`
+	TxTimestamp  int64  `json:"txTimestamp"`
+}
+
+// AnchorDocumentHash records a document hash issued by orgId and spends
+// documentAnchorFee of the org credit. The document itself stays off chain.
+// Anchors are kept per org so that another org anchoring the same hash can
+// neither block nor pass for the issuer
+func (s *SmartContract) AnchorDocumentHash(ctx contractapi.TransactionContextInterface, orgId string, hash string, docType string, metadata string) (*DocumentAnchor, error) {
+	hash = strings.ToLower(hash)
+	if err := validateInput(&documentAnchorInput{OrgID: orgId, Hash: hash, DocumentType: docType, Metadata: metadata}); err != nil {
+		return nil, err
+	}
+	stub := ctx.GetStub()
+	existing, err := s.readDocumentAnchor(stub, hash, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, newAlreadyExistsError("Document hash %s is already anchored by org %s", hash, orgId)
+	}
+	org, err := s.readOrg(stub, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if err = assertOrgNotArchived(org); err != nil {
+		return nil, err
+	}
+	if !org.IsActive {
+		return nil, newConflictError("Org %s is not active", orgId)
+	}
+	if org.PubKeyPem == "" {
+		return nil, newConflictError("Org %s has no public key", orgId)
+	}
+	pubKey, err := parseOrgPublicKey(org.PubKeyType, org.PubKeyPem)
+	if err != nil {
+		return nil, err
+	}
+	keyId, err := publicKeyFingerprint(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.burnOrgCredit(ctx, org.OrgCreditID, orgId, documentAnchorFee, "Anchor "+docType, ts.AsTime().Unix(), "spend"); err != nil {
+		return nil, err
+	}
+	actor, err := s.newActor(stub)
+	if err != nil {
+		return nil, err
+	}
+	anchor := &DocumentAnchor{
+		DocType:      "DocumentAnchor",
+		Hash:         hash,
+		OrgID:        orgId,
+		DocumentType: docType,
+		Metadata:     metadata,
+		KeyID:        keyId,
+		AnchoredBy:   actor,
+		TxID:         stub.GetTxID(),
+		TxTimestamp:  ts.AsTime().Unix(),
+	}
+	stateId, err := s.newDocumentAnchorStateId(stub, hash, orgId)
+	if err != nil {
+		return nil, err
+	}
+	anchorJSON, err := json.Marshal(anchor)
+	if err != nil {
+		return nil, err
+	}
+	if err = stub.PutState(stateId, anchorJSON); err != nil {
+		return nil, err
+	}
+	event := events.DocumentAnchor{Hash: hash, OrgID: orgId, DocumentType: docType, KeyID: keyId}
+	if err = s.emitEvent(ctx, events.DocumentAnchored, event); err != nil {
+		return nil, err
+	}
+	return anchor, nil
+}
+
+// LookupDocumentHash returns every anchor of a document hash, for verifiers
+// to check the issuer org among them. It fails with a not found error when no
+// org anchored the hash
+func (s *SmartContract) LookupDocumentHash(ctx contractapi.TransactionContextInterface, hash string) ([]*DocumentAnchor, error) {
+	hash = strings.ToLower(hash)
+	if err := validateInput(&documentHashInput{Hash: hash}); err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("DocumentAnchor", []string{hash})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	anchors := make([]*DocumentAnchor, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var anchor DocumentAnchor
+		if err = json.Unmarshal(queryResult.Value, &anchor); err != nil {
+			return nil, err
+		}
+		anchors = append(anchors, &anchor)
+	}
+	if len(anchors) == 0 {
+		return nil, newNotFoundError("Document hash %s is not anchored", hash)
+	}
+	return anchors, nil
+}
+
+// ReadDocumentAnchor returns the anchor of a document hash by the org that
+// claims to have issued it
+func (s *SmartContract) ReadDocumentAnchor(ctx contractapi.TransactionContextInterface, orgId string, hash string) (*DocumentAnchor, error) {
+	hash = strings.ToLower(hash)
+	if err := validateInput(&orgDocumentHashInput{OrgID: orgId, Hash: hash}); err != nil {
+		return nil, err
+	}
+	anchor, err := s.readDocumentAnchor(ctx.GetStub(), hash, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if anchor == nil {
+		return nil, newNotFoundError("Document hash %s is not anchored by org %s", hash, orgId)
+	}
+	return anchor, nil
+}
+
+// readDocumentAnchor returns nil when the hash is not anchored by the org
+func (s *SmartContract) readDocumentAnchor(stub shim.ChaincodeStubInterface, hash string, orgId string) (*DocumentAnchor, error) {
+	stateId, err := s.newDocumentAnchorStateId(stub, hash, orgId)
+	if err != nil {
+		return nil, err
+	}
+	anchorJSON, err := stub.GetState(stateId)
+	if err != nil || anchorJSON == nil {
+		return nil, err
+	}
+	var anchor DocumentAnchor
+	if err = json.Unmarshal(anchorJSON, &anchor); err != nil {
+		return nil, err
+	}
+	return &anchor, nil
+}
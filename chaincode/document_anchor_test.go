@@ -0,0 +1,86 @@
+package chaincode_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnchorDocumentHashPerOrg(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newEnrolledSuperAdmin(t, "su", "su")
+	approver := newEnrolledSuperAdmin(t, "approver", "approver")
+	for _, orgId := range []string{"ORG1", "ORG2"} {
+		createKeyedOrg(t, l, su, orgId)
+		org, err := sc.ReadOrg(l.as(su), orgId)
+		require.NoError(t, err)
+		proposal, err := sc.ProposeMint(l.as(su), org.OrgCreditID, orgId, "10", "anchoring")
+		require.NoError(t, err)
+		_, err = sc.ApproveProposal(l.as(approver), proposal.ID)
+		require.NoError(t, err)
+	}
+
+	// another org anchoring the hash first neither blocks nor passes for the issuer
+	_, err := sc.AnchorDocumentHash(l.as(su), "ORG2", documentHash, "diploma", "")
+	require.NoError(t, err)
+	_, err = sc.AnchorDocumentHash(l.as(su), "ORG1", documentHash, "diploma", "")
+	require.NoError(t, err)
+	_, err = sc.AnchorDocumentHash(l.as(su), "ORG1", documentHash, "diploma", "")
+	requireErrorCode(t, chaincode.ErrorCodeAlreadyExists, err)
+
+	anchor, err := sc.ReadDocumentAnchor(l.as(su), "ORG1", documentHash)
+	require.NoError(t, err)
+	require.Equal(t, "ORG1", anchor.OrgID)
+	_, err = sc.ReadDocumentAnchor(l.as(su), "ORG3", documentHash)
+	requireErrorCode(t, chaincode.ErrorCodeNotFound, err)
+}
+
+func TestLookupDocumentHash(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newEnrolledSuperAdmin(t, "su", "su")
+	approver := newEnrolledSuperAdmin(t, "approver", "approver")
+	keyIds := map[string]string{}
+	for _, orgId := range []string{"ORG1", "ORG2", "ORG3"} {
+		createKeyedOrg(t, l, su, orgId)
+		org, err := sc.ReadOrg(l.as(su), orgId)
+		require.NoError(t, err)
+		proposal, err := sc.ProposeMint(l.as(su), org.OrgCreditID, orgId, "10", "anchoring")
+		require.NoError(t, err)
+		_, err = sc.ApproveProposal(l.as(approver), proposal.ID)
+		require.NoError(t, err)
+	}
+	otherHash := strings.Repeat("cd", 32)
+	for _, orgId := range []string{"ORG2", "ORG1"} {
+		anchor, err := sc.AnchorDocumentHash(l.as(su), orgId, documentHash, "diploma", `{"n":1}`)
+		require.NoError(t, err)
+		keyIds[orgId] = anchor.KeyID
+	}
+	_, err := sc.AnchorDocumentHash(l.as(su), "ORG3", otherHash, "transcript", "")
+	require.NoError(t, err)
+
+	// the hash anchored by two orgs returns both anchors, in org id order
+	anchors, err := sc.LookupDocumentHash(l.as(su), strings.ToUpper(documentHash))
+	require.NoError(t, err)
+	require.Len(t, anchors, 2)
+	for i, orgId := range []string{"ORG1", "ORG2"} {
+		require.Equal(t, orgId, anchors[i].OrgID)
+		require.Equal(t, documentHash, anchors[i].Hash)
+		require.Equal(t, "diploma", anchors[i].DocumentType)
+		require.Equal(t, keyIds[orgId], anchors[i].KeyID)
+	}
+	require.NotEqual(t, anchors[0].KeyID, anchors[1].KeyID)
+
+	anchors, err = sc.LookupDocumentHash(l.as(su), otherHash)
+	require.NoError(t, err)
+	require.Len(t, anchors, 1)
+	require.Equal(t, "ORG3", anchors[0].OrgID)
+
+	_, err = sc.LookupDocumentHash(l.as(su), strings.Repeat("ef", 32))
+	requireErrorCode(t, chaincode.ErrorCodeNotFound, err)
+	_, err = sc.LookupDocumentHash(l.as(su), documentHash[2:])
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+}
@@ -1,5 +1,152 @@
 package chaincode
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 
-var InsufficientPermissionError = fmt.Errorf("Insufficient Permission")
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Error codes returned to clients in ContractError.Code
+const (
+	ErrorCodeNotFound          = "NOT_FOUND"
+	ErrorCodeAlreadyExists     = "ALREADY_EXISTS"
+	ErrorCodePermissionDenied  = "PERMISSION_DENIED"
+	ErrorCodeValidation        = "VALIDATION"
+	ErrorCodeInsufficientFunds = "INSUFFICIENT_FUNDS"
+	ErrorCodeConflict          = "CONFLICT"
+	ErrorCodeInternal          = "INTERNAL"
+)
+
+// ContractError is the error returned by transactions. The error message is
+// its JSON encoding, which is what clients receive as the response message
+type ContractError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+var InsufficientPermissionError = &ContractError{Code: ErrorCodePermissionDenied, Message: "Insufficient Permission"}
+
+func (e *ContractError) Error() string {
+	errorJSON, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf(`{"code":%q,"message":%q}`, e.Code, e.Message)
+	}
+	return string(errorJSON)
+}
+
+// Is matches any ContractError with the same code, so errors.Is(err,
+// InsufficientPermissionError) holds for every permission error
+func (e *ContractError) Is(target error) bool {
+	var contractError *ContractError
+	return errors.As(target, &contractError) && contractError.Code == e.Code
+}
+
+func newContractError(code string, format string, args ...interface{}) *ContractError {
+	return &ContractError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func newNotFoundError(format string, args ...interface{}) error {
+	return newContractError(ErrorCodeNotFound, format, args...)
+}
+
+func newAlreadyExistsError(format string, args ...interface{}) error {
+	return newContractError(ErrorCodeAlreadyExists, format, args...)
+}
+
+func newPermissionDeniedError(format string, args ...interface{}) error {
+	return newContractError(ErrorCodePermissionDenied, format, args...)
+}
+
+func newValidationError(format string, args ...interface{}) error {
+	return newContractError(ErrorCodeValidation, format, args...)
+}
+
+func newInsufficientFundsError(format string, args ...interface{}) error {
+	return newContractError(ErrorCodeInsufficientFunds, format, args...)
+}
+
+func newConflictError(format string, args ...interface{}) error {
+	return newContractError(ErrorCodeConflict, format, args...)
+}
+
+func newInternalError(format string, args ...interface{}) error {
+	return newContractError(ErrorCodeInternal, format, args...)
+}
+
+// ErrorCodeOf returns the code of err, ErrorCodeInternal for errors that are
+// not a ContractError
+func ErrorCodeOf(err error) string {
+	var contractError *ContractError
+	if errors.As(err, &contractError) {
+		return contractError.Code
+	}
+	return ErrorCodeInternal
+}
+
+// toContractError parses an error response message, wrapping messages that
+// are not a ContractError, like stub or contractapi errors
+func toContractError(message string) *ContractError {
+	var contractError ContractError
+	if err := json.Unmarshal([]byte(message), &contractError); err == nil && contractError.Code != "" {
+		return &contractError
+	}
+	if strings.HasPrefix(message, "Error managing parameter") {
+		return &ContractError{Code: ErrorCodeValidation, Message: message}
+	}
+	if strings.HasPrefix(message, "Function ") && strings.Contains(message, " not found in contract ") {
+		return &ContractError{Code: ErrorCodeNotFound, Message: message}
+	}
+	return &ContractError{Code: ErrorCodeInternal, Message: message}
+}
+
+// Chaincode serves the contract and rewrites every error response so that its
+// message is a ContractError
+type Chaincode struct {
+	*contractapi.ContractChaincode
+}
+
+func NewChaincode(contract contractapi.ContractInterface) (*Chaincode, error) {
+	cc, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		return nil, err
+	}
+	return &Chaincode{ContractChaincode: cc}, nil
+}
+
+// Start starts the chaincode like contractapi does, as a chaincode server when
+// CHAINCODE_SERVER_ADDRESS and CORE_CHAINCODE_ID_NAME are set and otherwise
+// launched by the peer. ContractChaincode.Start would serve the embedded
+// chaincode and skip the error rewriting
+func (cc *Chaincode) Start() error {
+	server, err := chaincodeServerConfig()
+	if err != nil {
+		return err
+	}
+	if server != nil {
+		server.CC = cc
+		return server.Start()
+	}
+	return shim.Start(cc)
+}
+
+func (cc *Chaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	return withContractError(cc.ContractChaincode.Init(stub))
+}
+
+func (cc *Chaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	return withContractError(cc.ContractChaincode.Invoke(stub))
+}
+
+func withContractError(response peer.Response) peer.Response {
+	if response.Status < shim.ERRORTHRESHOLD {
+		return response
+	}
+	response.Message = toContractError(response.Message).Error()
+	return response
+}
@@ -0,0 +1,73 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/stretchr/testify/require"
+)
+
+// invoke runs fn through the chaincode the way the peer does
+func invoke(t *testing.T, l *ledger, cc *chaincode.Chaincode, creator []byte, fn string, args ...string) (*chaincode.ContractError, []byte) {
+	l.as(creator)
+	l.stub.GetFunctionAndParametersReturns(fn, args)
+	response := cc.Invoke(l.stub)
+	if response.Status < shim.ERRORTHRESHOLD {
+		return nil, response.Payload
+	}
+	var contractError chaincode.ContractError
+	require.NoError(t, json.Unmarshal([]byte(response.Message), &contractError), response.Message)
+	return &contractError, nil
+}
+
+func TestChaincodeErrorResponses(t *testing.T) {
+	l := newLedger(t)
+	su := newSuperAdmin(t, "su")
+	cc, err := chaincode.NewChaincode(&chaincode.SmartContract{})
+	require.NoError(t, err)
+	createOrg(t, l, su, "ORG1")
+
+	// a successful response passes through unchanged
+	l.as(su)
+	l.stub.GetFunctionAndParametersReturns("ReadOrg", []string{"ORG1"})
+	require.Equal(t, cc.ContractChaincode.Invoke(l.stub), cc.Invoke(l.stub))
+	contractError, payload := invoke(t, l, cc, su, "ReadOrg", "ORG1")
+	require.Nil(t, contractError)
+	var org chaincode.Organization
+	require.NoError(t, json.Unmarshal(payload, &org))
+	require.Equal(t, "ORG1", org.ID)
+
+	// a typed error keeps its code and message
+	contractError, _ = invoke(t, l, cc, su, "ReadOrg", "ORG2")
+	require.Equal(t, chaincode.ErrorCodeNotFound, contractError.Code)
+	require.Contains(t, contractError.Message, "ORG2")
+	contractError, _ = invoke(t, l, cc, su, "CreateOrg", "ORG1", "Org One", "", "", "", "", "", "", "true")
+	require.Equal(t, chaincode.ErrorCodeAlreadyExists, contractError.Code)
+
+	// contractapi errors are mapped by their message
+	contractError, _ = invoke(t, l, cc, su, "ListOrgsPaginated", "", "ten", "")
+	require.Equal(t, chaincode.ErrorCodeValidation, contractError.Code)
+	// transactions without a policy are denied before they are looked up
+	contractError, _ = invoke(t, l, cc, su, "DropOrg", "ORG1")
+	require.Equal(t, chaincode.ErrorCodePermissionDenied, contractError.Code)
+
+	// a plain Go error is internal
+	getState := l.stub.GetStateStub
+	l.stub.GetStateStub = func(key string) ([]byte, error) {
+		return nil, errors.New("ledger unavailable")
+	}
+	contractError, _ = invoke(t, l, cc, su, "ReadOrg", "ORG1")
+	require.Equal(t, chaincode.ErrorCodeInternal, contractError.Code)
+	require.Contains(t, contractError.Message, "ledger unavailable")
+	l.stub.GetStateStub = getState
+}
+
+func TestErrorCodeOf(t *testing.T) {
+	require.Equal(t, chaincode.ErrorCodePermissionDenied, chaincode.ErrorCodeOf(chaincode.InsufficientPermissionError))
+	require.Equal(t, chaincode.ErrorCodeConflict, chaincode.ErrorCodeOf(&chaincode.ContractError{Code: chaincode.ErrorCodeConflict}))
+	require.Equal(t, chaincode.ErrorCodeInternal, chaincode.ErrorCodeOf(errors.New("plain")))
+	require.True(t, errors.Is(&chaincode.ContractError{Code: chaincode.ErrorCodePermissionDenied, Message: "other"}, chaincode.InsufficientPermissionError))
+}
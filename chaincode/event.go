@@ -0,0 +1,71 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransactionContext buffers the events emitted during a transaction. Fabric
+// keeps only the last chaincode event of a transaction, so every emit rewrites
+// the event with all buffered events
+type TransactionContext struct {
+	contractapi.TransactionContext
+	events []events.Event
+}
+
+func (s *SmartContract) GetTransactionContextHandler() contractapi.SettableTransactionContextInterface {
+	return new(TransactionContext)
+}
+
+// emitEvent appends a typed event to the transaction event envelope
+func (s *SmartContract) emitEvent(ctx contractapi.TransactionContextInterface, eventType string, data interface{}) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	event := events.Event{Type: eventType, Version: events.Version, Data: dataJSON}
+	batch := []events.Event{event}
+	if txCtx, ok := ctx.(*TransactionContext); ok {
+		txCtx.events = append(txCtx.events, event)
+		batch = txCtx.events
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	mspId, clientId, err := getClientIdentity(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	envelopeJSON, err := json.Marshal(events.Envelope{
+		Version:     events.Version,
+		TxID:        ctx.GetStub().GetTxID(),
+		TxTimestamp: ts.AsTime().Unix(),
+		Actor:       events.Actor{MSPID: mspId, ID: clientId},
+		Events:      batch,
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().SetEvent(events.Name, envelopeJSON)
+}
+
+func newOrgEvent(org *Organization) events.Org {
+	return events.Org{
+		ID:              org.ID,
+		Name:            org.Name,
+		Email:           org.Email,
+		InstitutionID:   org.InstitutionID,
+		InstitutionName: org.InstitutionName,
+		Desc:            org.Desc,
+		OrgCreditID:     org.OrgCreditID,
+		LogoUrl:         org.LogoUrl,
+		IsActive:        org.IsActive,
+		PubKeyType:      org.PubKeyType,
+		PubKeyPem:       org.PubKeyPem,
+		ParentOrgID:     org.ParentOrgID,
+		ArchivedAt:      org.ArchivedAt,
+	}
+}
@@ -0,0 +1,99 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/stretchr/testify/require"
+)
+
+// txContext starts a transaction submitted by creator with the buffering
+// context fabric hands to the contract
+func txContext(l *ledger, creator []byte) *chaincode.TransactionContext {
+	ctx := new(chaincode.TransactionContext)
+	ctx.SetStub(l.as(creator).GetStub())
+	return ctx
+}
+
+// lastEnvelope decodes the last chaincode event, the one fabric keeps
+func lastEnvelope(t *testing.T, l *ledger) events.Envelope {
+	require.NotZero(t, l.stub.SetEventCallCount())
+	name, payload := l.stub.SetEventArgsForCall(l.stub.SetEventCallCount() - 1)
+	require.Equal(t, events.Name, name)
+	var envelope events.Envelope
+	require.NoError(t, json.Unmarshal(payload, &envelope))
+	return envelope
+}
+
+func eventTypes(envelope events.Envelope) []string {
+	types := make([]string, 0, len(envelope.Events))
+	for _, event := range envelope.Events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestEventsOfOrgLifecycle(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+
+	_, err := sc.CreateOrgWithInput(txContext(l, su), chaincode.CreateOrgInput{OrgID: "ORG1", Name: "Org 1"})
+	require.NoError(t, err)
+	envelope := lastEnvelope(t, l)
+	require.Equal(t, events.Version, envelope.Version)
+	require.Equal(t, "tx1", envelope.TxID)
+	require.Equal(t, l.now.Unix(), envelope.TxTimestamp)
+	require.Equal(t, "DsolutionsOrgMSP", envelope.Actor.MSPID)
+	require.Equal(t, []string{events.CreditCreated, events.OrgCreated}, eventTypes(envelope))
+	var org events.Org
+	require.NoError(t, envelope.Events[1].Decode(&org))
+	require.Equal(t, "ORG1", org.ID)
+	require.Equal(t, "Org 1", org.Name)
+	require.True(t, org.IsActive)
+
+	setOrgKey(t, l, su, "ORG1")
+	envelope = lastEnvelope(t, l)
+	require.Equal(t, []string{events.OrgKeySet}, eventTypes(envelope))
+	require.NoError(t, envelope.Events[0].Decode(&org))
+	require.Equal(t, "ecdsa:P-384", org.PubKeyType)
+
+	require.NoError(t, sc.RemoveOrgPublicKey(txContext(l, su), "ORG1"))
+	envelope = lastEnvelope(t, l)
+	require.Equal(t, []string{events.OrgKeyRemoved}, eventTypes(envelope))
+	require.NoError(t, envelope.Events[0].Decode(&org))
+	require.Empty(t, org.PubKeyPem)
+
+	require.NoError(t, sc.ArchiveOrg(txContext(l, su), "ORG1"))
+	require.Equal(t, []string{events.OrgArchived}, eventTypes(lastEnvelope(t, l)))
+}
+
+func TestEventsOfCreditChanges(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	alice := newEnrolledSuperAdmin(t, "alice", "alice")
+	bob := newEnrolledSuperAdmin(t, "bob", "bob")
+	createOrg(t, l, alice, "ORG1")
+	org, err := sc.ReadOrg(l.as(alice), "ORG1")
+	require.NoError(t, err)
+	proposal, err := sc.ProposeMint(txContext(l, alice), org.OrgCreditID, "ORG1", "10", "grant")
+	require.NoError(t, err)
+	require.Equal(t, []string{events.ProposalCreated, events.ProposalApproved}, eventTypes(lastEnvelope(t, l)))
+
+	_, err = sc.ApproveProposal(txContext(l, bob), proposal.ID)
+	require.NoError(t, err)
+	envelope := lastEnvelope(t, l)
+	require.Equal(t, []string{events.ProposalApproved, events.CreditMinted, events.ProposalExecuted}, eventTypes(envelope))
+	var credit events.Credit
+	require.NoError(t, envelope.Events[1].Decode(&credit))
+	require.Equal(t, "ORG1", credit.OrgID)
+	// the credit log books a mint as a debit of the credit account
+	require.Equal(t, "10", credit.Debit)
+	require.Equal(t, "10", credit.Balance)
+	var executed events.Proposal
+	require.NoError(t, envelope.Events[2].Decode(&executed))
+	require.Equal(t, chaincode.ProposalStatusExecuted, executed.Status)
+	require.Equal(t, 2, executed.Approvals)
+}
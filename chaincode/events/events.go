@@ -0,0 +1,242 @@
+// Package events defines the chaincode events emitted by the Organization
+// contract. Fabric keeps a single chaincode event per transaction, so every
+// transaction emits one event named Name whose payload is an Envelope holding
+// all changes made by the transaction in order.
+//
+// Consumers decode the payload with json.Unmarshal into an Envelope, switch on
+// Event.Type and decode Event.Data into the matching data struct:
+//
+//	OrgCreated, OrgUpdated, OrgKeySet, OrgKeyRemoved,
+//	OrgArchived, OrgPurged  Org
+//	CreditCreated, CreditMinted, CreditBurned, CreditSpent  Credit
+//	OrgRoleGranted, OrgRoleRevoked  OrgRole
+//	OrgRoleDelegated, OrgRoleDelegationRevoked  OrgRoleDelegation
+//	ProposalCreated, ProposalApproved, ProposalRejected,
+//	ProposalExecuted, ProposalExpired  Proposal
+//	GovernancePolicyUpdated  GovernancePolicy
+//	PlatformConfigUpdated  PlatformConfig
+//	IdentityDenied, IdentityAllowed  DeniedIdentity
+//	OrgApplicationSubmitted, OrgApplicationApproved,
+//	OrgApplicationRejected  OrgApplication
+//	AccreditationAdded, AccreditationRevoked  Accreditation
+//	OrgTrustGranted, OrgTrustRevoked  OrgTrust
+//	OrgSigningPolicySet  OrgSigningPolicy
+//	SigningRequestOpened, SigningRequestSigned, SigningRequestCompleted,
+//	SigningRequestCancelled  SigningRequest
+//	DocumentAnchored  DocumentAnchor
+//
+// Version is increased whenever a data struct changes in a way that is not
+// backwards compatible.
+package events
+
+import "encoding/json"
+
+// Name is the chaincode event name used by every transaction
+const Name = "diplom-mn.organization"
+
+// Version is the schema version of the event data structs
+const Version = 1
+
+const (
+	OrgCreated    = "OrgCreated"
+	OrgUpdated    = "OrgUpdated"
+	OrgKeySet     = "OrgKeySet"
+	OrgKeyRemoved = "OrgKeyRemoved"
+	OrgArchived   = "OrgArchived"
+	OrgPurged     = "OrgPurged"
+
+	CreditCreated = "CreditCreated"
+	CreditMinted  = "CreditMinted"
+	CreditBurned  = "CreditBurned"
+	CreditSpent   = "CreditSpent"
+
+	OrgRoleGranted           = "OrgRoleGranted"
+	OrgRoleRevoked           = "OrgRoleRevoked"
+	OrgRoleDelegated         = "OrgRoleDelegated"
+	OrgRoleDelegationRevoked = "OrgRoleDelegationRevoked"
+
+	ProposalCreated  = "ProposalCreated"
+	ProposalApproved = "ProposalApproved"
+	ProposalRejected = "ProposalRejected"
+	ProposalExecuted = "ProposalExecuted"
+	ProposalExpired  = "ProposalExpired"
+
+	GovernancePolicyUpdated = "GovernancePolicyUpdated"
+	PlatformConfigUpdated   = "PlatformConfigUpdated"
+
+	IdentityDenied  = "IdentityDenied"
+	IdentityAllowed = "IdentityAllowed"
+
+	OrgApplicationSubmitted = "OrgApplicationSubmitted"
+	OrgApplicationApproved  = "OrgApplicationApproved"
+	OrgApplicationRejected  = "OrgApplicationRejected"
+
+	AccreditationAdded   = "AccreditationAdded"
+	AccreditationRevoked = "AccreditationRevoked"
+
+	OrgTrustGranted = "OrgTrustGranted"
+	OrgTrustRevoked = "OrgTrustRevoked"
+
+	OrgSigningPolicySet = "OrgSigningPolicySet"
+
+	SigningRequestOpened    = "SigningRequestOpened"
+	SigningRequestSigned    = "SigningRequestSigned"
+	SigningRequestCompleted = "SigningRequestCompleted"
+	SigningRequestCancelled = "SigningRequestCancelled"
+
+	DocumentAnchored = "DocumentAnchored"
+)
+
+// Envelope is the payload of the chaincode event
+type Envelope struct {
+	Version     int     `json:"version"`
+	TxID        string  `json:"txId"`
+	TxTimestamp int64   `json:"txTimestamp"`
+	Actor       Actor   `json:"actor"`
+	Events      []Event `json:"events"`
+}
+
+// Event is a single state change. Data holds one of the data structs below
+type Event struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Decode unmarshals the event data into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Actor is the identity that submitted the transaction
+type Actor struct {
+	MSPID string `json:"mspId"`
+	ID    string `json:"id"`
+}
+
+type Org struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Email           string `json:"email"`
+	InstitutionID   string `json:"institutionId"`
+	InstitutionName string `json:"institutionName"`
+	Desc            string `json:"desc"`
+	OrgCreditID     string `json:"orgCreditId"`
+	LogoUrl         string `json:"logoUrl"`
+	IsActive        bool   `json:"isActive"`
+	PubKeyType      string `json:"pubKeyType"`
+	PubKeyPem       string `json:"pubKeyPem"`
+	ParentOrgID     string `json:"parentOrgId,omitempty"`
+	ArchivedAt      int64  `json:"archivedAt,omitempty"`
+}
+
+type Credit struct {
+	ID      string `json:"id"`
+	OrgID   string `json:"orgId"`
+	LogID   string `json:"logId"`
+	Title   string `json:"title"`
+	Credit  string `json:"credit"`
+	Debit   string `json:"debit"`
+	Balance string `json:"balance"`
+}
+
+type OrgRole struct {
+	OrgID        string `json:"orgId"`
+	MSPID        string `json:"mspId"`
+	IdentityHash string `json:"identityHash"`
+	Role         string `json:"role"`
+}
+
+type OrgRoleDelegation struct {
+	ID                  string `json:"id"`
+	OrgID               string `json:"orgId"`
+	Role                string `json:"role"`
+	GranteeMSPID        string `json:"granteeMspId"`
+	GranteeIdentityHash string `json:"granteeIdentityHash"`
+	ExpiresAt           int64  `json:"expiresAt"`
+}
+
+type Proposal struct {
+	ID        string `json:"id"`
+	Operation string `json:"operation"`
+	Payload   string `json:"payload"`
+	Status    string `json:"status"`
+	Threshold int    `json:"threshold"`
+	Approvals int    `json:"approvals"`
+	Reason    string `json:"reason"`
+}
+
+type GovernancePolicy struct {
+	Operation  string `json:"operation"`
+	Threshold  int    `json:"threshold"`
+	TTLSeconds int64  `json:"ttlSeconds"`
+}
+
+type PlatformConfig struct {
+	AdminMSPIDs      []string `json:"adminMspIds"`
+	ClientMSPIDs     []string `json:"clientMspIds"`
+	AccreditorMSPIDs []string `json:"accreditorMspIds,omitempty"`
+}
+
+type DeniedIdentity struct {
+	Kind   string `json:"kind"`
+	Issuer string `json:"issuer,omitempty"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+type OrgApplication struct {
+	ID             string `json:"id"`
+	OrgID          string `json:"orgId"`
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	Reason         string `json:"reason"`
+	ApplicantMSPID string `json:"applicantMspId"`
+}
+
+type Accreditation struct {
+	ID              string   `json:"id"`
+	OrgID           string   `json:"orgId"`
+	AccreditingBody string   `json:"accreditingBody"`
+	EducationLevels []string `json:"educationLevels"`
+	FieldCodes      []string `json:"fieldCodes"`
+	ValidFrom       int64    `json:"validFrom"`
+	ValidTo         int64    `json:"validTo"`
+	DocumentHash    string   `json:"documentHash"`
+	RevokedAt       int64    `json:"revokedAt,omitempty"`
+	Reason          string   `json:"reason,omitempty"`
+	RevokedBy       *Actor   `json:"revokedBy,omitempty"`
+}
+
+type OrgTrust struct {
+	ID           string `json:"id"`
+	OrgID        string `json:"orgId"`
+	TrustedOrgID string `json:"trustedOrgId"`
+	Purpose      string `json:"purpose"`
+	ValidFrom    int64  `json:"validFrom"`
+	ValidTo      int64  `json:"validTo"`
+}
+
+type OrgSigningPolicy struct {
+	OrgID        string   `json:"orgId"`
+	SignedKeys   []string `json:"signedKeys"`
+	CoSignerIDs  []string `json:"coSignerIds"`
+	MinSigners   int      `json:"minSigners"`
+	TrustPurpose string   `json:"trustPurpose,omitempty"`
+}
+
+type SigningRequest struct {
+	ID              string   `json:"id"`
+	IssuerOrgID     string   `json:"issuerOrgId"`
+	DocumentHash    string   `json:"documentHash"`
+	RequiredSigners []string `json:"requiredSigners"`
+	SignedOrgIDs    []string `json:"signedOrgIds"`
+	Status          string   `json:"status"`
+}
+
+type DocumentAnchor struct {
+	Hash         string `json:"hash"`
+	OrgID        string `json:"orgId"`
+	DocumentType string `json:"documentType"`
+	KeyID        string `json:"keyId"`
+}
@@ -0,0 +1,289 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Operation types that can be routed through governance proposals
+const (
+	OperationCreditMint       = "credit.mint"
+	OperationCreditBurn       = "credit.burn"
+	OperationOrgKeySet        = "org.key.set"
+	OperationOrgKeyRemove     = "org.key.remove"
+	OperationOrgDeactivate    = "org.deactivate"
+	OperationGovernancePolicy = "governance.policy"
+	OperationPlatformConfig   = "platform.config"
+)
+
+const defaultProposalTTL = 7 * 24 * 60 * 60
+
+var defaultGovernanceThresholds = map[string]int{
+	OperationCreditMint:       2,
+	OperationCreditBurn:       2,
+	OperationOrgKeySet:        1,
+	OperationOrgKeyRemove:     1,
+	OperationOrgDeactivate:    1,
+	OperationGovernancePolicy: 2,
+	OperationPlatformConfig:   1,
+}
+
+var governedOperations = []string{
+	OperationCreditMint,
+	OperationCreditBurn,
+	OperationOrgKeySet,
+	OperationOrgKeyRemove,
+	OperationOrgDeactivate,
+	OperationGovernancePolicy,
+	OperationPlatformConfig,
+}
+
+type GovernancePolicy struct {
+	DocType     string `json:"docType"`
+	Operation   string `json:"operation"`
+	Threshold   int    `json:"threshold"`
+	TTLSeconds  int64  `json:"ttlSeconds"`
+	UpdatedBy   Actor  `json:"updatedBy" metadata:",optional"`
+	TxTimestamp int64  `json:"txTimestamp"`
+}
+
+// CreditChangePayload amounts are positive decimals with at most 2 decimal places
+type CreditChangePayload struct {
+	CreditID string `json:"creditId" validate:"required,id"`
+	OrgID    string `json:"orgId" validate:"required,id"`
+	Amount   string `json:"amount" validate:"required,decimal=2,positive"`
+	Title    string `json:"title" validate:"max=256"`
+}
+
+type OrgKeyPayload struct {
+	OrgID      string `json:"orgId" validate:"required,id"`
+	PubKeyType string `json:"pubKeyType" validate:"required,oneof=ecdsa:P-384"`
+	PubKeyPem  string `json:"pubKeyPem" validate:"required,max=4096"`
+}
+
+type OrgStatusPayload struct {
+	OrgID  string `json:"orgId" validate:"required,id"`
+	Reason string `json:"reason" validate:"max=1024"`
+}
+
+func (s *SmartContract) ReadGovernancePolicy(ctx contractapi.TransactionContextInterface, operation string) (*GovernancePolicy, error) {
+	return s.readGovernancePolicy(ctx.GetStub(), operation)
+}
+
+func (s *SmartContract) ListGovernancePolicies(ctx contractapi.TransactionContextInterface) ([]*GovernancePolicy, error) {
+	policies := make([]*GovernancePolicy, 0, len(governedOperations))
+	for _, operation := range governedOperations {
+		policy, err := s.readGovernancePolicy(ctx.GetStub(), operation)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// SetGovernancePolicy changes the approval threshold of an operation type. It is
+// itself a governed operation and needs a proposal once its threshold is above 1
+func (s *SmartContract) SetGovernancePolicy(ctx contractapi.TransactionContextInterface, operation string, threshold int, ttlSeconds int64) error {
+	if err := s.assertDirectOperationAllowed(ctx.GetStub(), OperationGovernancePolicy); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(GovernancePolicy{Operation: operation, Threshold: threshold, TTLSeconds: ttlSeconds})
+	if err != nil {
+		return err
+	}
+	return s.executeOperation(ctx, OperationGovernancePolicy, string(payload))
+}
+
+func (s *SmartContract) readGovernancePolicy(stub shim.ChaincodeStubInterface, operation string) (*GovernancePolicy, error) {
+	threshold, ok := defaultGovernanceThresholds[operation]
+	if !ok {
+		return nil, newValidationError("Unknown operation %s", operation)
+	}
+	stateId, err := s.newGovernancePolicyStateId(stub, operation)
+	if err != nil {
+		return nil, err
+	}
+	policyJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	policy := GovernancePolicy{
+		DocType:    "GovernancePolicy",
+		Operation:  operation,
+		Threshold:  threshold,
+		TTLSeconds: defaultProposalTTL,
+	}
+	if policyJSON == nil {
+		return &policy, nil
+	}
+	if err = json.Unmarshal(policyJSON, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// requiresApproval reports whether an operation must go through a proposal
+func (s *SmartContract) requiresApproval(stub shim.ChaincodeStubInterface, operation string) (bool, error) {
+	policy, err := s.readGovernancePolicy(stub, operation)
+	if err != nil {
+		return false, err
+	}
+	return policy.Threshold > 1, nil
+}
+
+func (s *SmartContract) assertDirectOperationAllowed(stub shim.ChaincodeStubInterface, operation string) error {
+	requiresApproval, err := s.requiresApproval(stub, operation)
+	if err != nil {
+		return err
+	}
+	if requiresApproval {
+		return newPermissionDeniedError("Operation %s requires approval, submit it with ProposeOperation", operation)
+	}
+	return nil
+}
+
+// validateOperationPayload checks that a proposal payload can be executed later
+func (s *SmartContract) validateOperationPayload(operation string, payload string) error {
+	switch operation {
+	case OperationCreditMint, OperationCreditBurn:
+		var p CreditChangePayload
+		if err := json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		if err := validateInput(&p); err != nil {
+			return err
+		}
+	case OperationOrgKeySet:
+		var p OrgKeyPayload
+		if err := json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		if err := validateInput(&p); err != nil {
+			return err
+		}
+		if _, err := parseOrgPublicKey(p.PubKeyType, p.PubKeyPem); err != nil {
+			return err
+		}
+	case OperationOrgKeyRemove, OperationOrgDeactivate:
+		var p OrgStatusPayload
+		if err := json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		if err := validateInput(&p); err != nil {
+			return err
+		}
+	case OperationGovernancePolicy:
+		var p GovernancePolicy
+		if err := json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		if _, ok := defaultGovernanceThresholds[p.Operation]; !ok {
+			return newValidationError("Unknown operation %s", p.Operation)
+		}
+		if p.Threshold < 1 {
+			return newValidationError("Threshold should be at least 1")
+		}
+		if p.TTLSeconds <= 0 {
+			return newValidationError("TTL should be greater than 0")
+		}
+	case OperationPlatformConfig:
+		var p PlatformConfig
+		if err := json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		if err := validatePlatformConfig(&p); err != nil {
+			return err
+		}
+	default:
+		return newValidationError("Unknown operation %s", operation)
+	}
+	return nil
+}
+
+// executeOperation applies an operation without checking any permission
+func (s *SmartContract) executeOperation(ctx contractapi.TransactionContextInterface, operation string, payload string) error {
+	if err := s.validateOperationPayload(operation, payload); err != nil {
+		return err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	switch operation {
+	case OperationCreditMint:
+		var p CreditChangePayload
+		if err = json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		return s.mintOrgCredit(ctx, p.CreditID, p.OrgID, p.Amount, p.Title, ts.AsTime().Unix(), "mint")
+	case OperationCreditBurn:
+		var p CreditChangePayload
+		if err = json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		return s.burnOrgCredit(ctx, p.CreditID, p.OrgID, p.Amount, p.Title, ts.AsTime().Unix(), "burn")
+	case OperationOrgKeySet:
+		var p OrgKeyPayload
+		if err = json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		return s.setOrgPublicKey(ctx, p.OrgID, p.PubKeyType, p.PubKeyPem)
+	case OperationOrgKeyRemove:
+		var p OrgStatusPayload
+		if err = json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		return s.removeOrgPublicKey(ctx, p.OrgID)
+	case OperationOrgDeactivate:
+		var p OrgStatusPayload
+		if err = json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		return s.setOrgActive(ctx, p.OrgID, false)
+	case OperationGovernancePolicy:
+		var p GovernancePolicy
+		if err = json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		stateId, err := s.newGovernancePolicyStateId(ctx.GetStub(), p.Operation)
+		if err != nil {
+			return err
+		}
+		actor, err := s.newActor(ctx.GetStub())
+		if err != nil {
+			return err
+		}
+		policy := GovernancePolicy{
+			DocType:     "GovernancePolicy",
+			Operation:   p.Operation,
+			Threshold:   p.Threshold,
+			TTLSeconds:  p.TTLSeconds,
+			UpdatedBy:   actor,
+			TxTimestamp: ts.AsTime().Unix(),
+		}
+		policyJSON, err := json.Marshal(policy)
+		if err != nil {
+			return err
+		}
+		if err = ctx.GetStub().PutState(stateId, policyJSON); err != nil {
+			return err
+		}
+		event := events.GovernancePolicy{Operation: policy.Operation, Threshold: policy.Threshold, TTLSeconds: policy.TTLSeconds}
+		return s.emitEvent(ctx, events.GovernancePolicyUpdated, event)
+	case OperationPlatformConfig:
+		var p PlatformConfig
+		if err = json.Unmarshal([]byte(payload), &p); err != nil {
+			return err
+		}
+		if err = s.putPlatformConfig(ctx.GetStub(), &p); err != nil {
+			return err
+		}
+		event := events.PlatformConfig{AdminMSPIDs: p.AdminMSPIDs, ClientMSPIDs: p.ClientMSPIDs, AccreditorMSPIDs: p.AccreditorMSPIDs}
+		return s.emitEvent(ctx, events.PlatformConfigUpdated, event)
+	}
+	return newValidationError("Unknown operation %s", operation)
+}
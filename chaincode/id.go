@@ -1,6 +1,8 @@
 package chaincode
 
 import (
+	"math"
+
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 )
 
@@ -15,3 +17,99 @@ func (s *SmartContract) newOrgCreditStateId(stub shim.ChaincodeStubInterface, id
 func (s *SmartContract) newOrgCreditLogStateId(stub shim.ChaincodeStubInterface, id string) (string, error) {
 	return stub.CreateCompositeKey("OrganizationCreditLog", []string{id})
 }
+
+func (s *SmartContract) newProposalStateId(stub shim.ChaincodeStubInterface, id string) (string, error) {
+	return stub.CreateCompositeKey("Proposal", []string{id})
+}
+
+func (s *SmartContract) newGovernancePolicyStateId(stub shim.ChaincodeStubInterface, operation string) (string, error) {
+	return stub.CreateCompositeKey("GovernancePolicy", []string{operation})
+}
+
+func (s *SmartContract) newPlatformConfigStateId(stub shim.ChaincodeStubInterface) (string, error) {
+	return stub.CreateCompositeKey("PlatformConfig", []string{})
+}
+
+func (s *SmartContract) newOrgMembershipStateId(stub shim.ChaincodeStubInterface, orgId string, mspId string, identityHash string) (string, error) {
+	return stub.CreateCompositeKey("OrgMembership", []string{orgId, mspId, identityHash})
+}
+
+func (s *SmartContract) newOrgRoleDelegationStateId(stub shim.ChaincodeStubInterface, id string) (string, error) {
+	return stub.CreateCompositeKey("OrgRoleDelegation", []string{id})
+}
+
+func (s *SmartContract) newOrgRoleDelegationGranteeIndexId(stub shim.ChaincodeStubInterface, orgId string, mspId string, identityHash string, id string) (string, error) {
+	return stub.CreateCompositeKey("OrgRoleDelegation~grantee", []string{orgId, mspId, identityHash, id})
+}
+
+func (s *SmartContract) newOrgTrustStateId(stub shim.ChaincodeStubInterface, id string) (string, error) {
+	return stub.CreateCompositeKey("OrgTrust", []string{id})
+}
+
+func (s *SmartContract) newOrgTrustIndexId(stub shim.ChaincodeStubInterface, orgId string, trustedOrgId string, purpose string, id string) (string, error) {
+	return stub.CreateCompositeKey("OrgTrust~trusted", []string{orgId, trustedOrgId, purpose, id})
+}
+
+func (s *SmartContract) newOrgTrustTrusterIndexId(stub shim.ChaincodeStubInterface, trustedOrgId string, orgId string, purpose string, id string) (string, error) {
+	return stub.CreateCompositeKey("OrgTrust~truster", []string{trustedOrgId, orgId, purpose, id})
+}
+
+func (s *SmartContract) newOrgSigningPolicyStateId(stub shim.ChaincodeStubInterface, orgId string) (string, error) {
+	return stub.CreateCompositeKey("OrgSigningPolicy", []string{orgId})
+}
+
+func (s *SmartContract) newSigningRequestStateId(stub shim.ChaincodeStubInterface, id string) (string, error) {
+	return stub.CreateCompositeKey("SigningRequest", []string{id})
+}
+
+func (s *SmartContract) newSigningRequestSignerIndexId(stub shim.ChaincodeStubInterface, signerOrgId string, id string) (string, error) {
+	return stub.CreateCompositeKey("SigningRequest~signer", []string{signerOrgId, id})
+}
+
+func (s *SmartContract) newSigningRequestOrgIndexId(stub shim.ChaincodeStubInterface, orgId string, id string) (string, error) {
+	return stub.CreateCompositeKey("SigningRequest~org", []string{orgId, id})
+}
+
+func (s *SmartContract) newDocumentAnchorStateId(stub shim.ChaincodeStubInterface, hash string, orgId string) (string, error) {
+	return stub.CreateCompositeKey("DocumentAnchor", []string{hash, orgId})
+}
+
+// newDeniedIdentityStateId leaves out an empty issuer, ids carry their issuer
+func (s *SmartContract) newDeniedIdentityStateId(stub shim.ChaincodeStubInterface, kind string, issuer string, value string) (string, error) {
+	if issuer == "" {
+		return stub.CreateCompositeKey("DeniedIdentity", []string{kind, value})
+	}
+	return stub.CreateCompositeKey("DeniedIdentity", []string{kind, issuer, value})
+}
+
+func (s *SmartContract) newOrgApplicationStateId(stub shim.ChaincodeStubInterface, id string) (string, error) {
+	return stub.CreateCompositeKey("OrgApplication", []string{id})
+}
+
+func (s *SmartContract) newAccreditationStateId(stub shim.ChaincodeStubInterface, orgId string, id string) (string, error) {
+	return stub.CreateCompositeKey("Accreditation", []string{orgId, id})
+}
+
+func (s *SmartContract) newOrgCreatedIndexId(stub shim.ChaincodeStubInterface, ts int64, orgId string) (string, error) {
+	return stub.CreateCompositeKey(orgCreatedIndex, []string{sortableTimestamp(ts), orgId})
+}
+
+func (s *SmartContract) newOrgInstitutionIndexId(stub shim.ChaincodeStubInterface, institutionId string, orgId string) (string, error) {
+	return stub.CreateCompositeKey(orgInstitutionIndex, []string{institutionId, orgId})
+}
+
+func (s *SmartContract) newOrgParentIndexId(stub shim.ChaincodeStubInterface, parentOrgId string, orgId string) (string, error) {
+	return stub.CreateCompositeKey(orgParentIndex, []string{parentOrgId, orgId})
+}
+
+func (s *SmartContract) newOrgPubKeyIndexId(stub shim.ChaincodeStubInterface, fingerprint string, orgId string) (string, error) {
+	return stub.CreateCompositeKey(orgPubKeyIndex, []string{fingerprint, orgId})
+}
+
+func (s *SmartContract) newOrgCreditLogIndexId(stub shim.ChaincodeStubInterface, orgId string, creditId string, ts int64, id string) (string, error) {
+	return stub.CreateCompositeKey(orgCreditLogIndex, []string{orgId, creditId, sortableTimestamp(ts), id})
+}
+
+func (s *SmartContract) newOrgCreditLogDescIndexId(stub shim.ChaincodeStubInterface, orgId string, creditId string, ts int64, id string) (string, error) {
+	return stub.CreateCompositeKey(orgCreditLogDescIndex, []string{orgId, creditId, sortableTimestamp(math.MaxInt64 - ts), id})
+}
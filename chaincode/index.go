@@ -0,0 +1,235 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key indexes maintained on write. Range reads over composite keys
+// work on LevelDB and CouchDB and are re-validated at commit
+const (
+	orgCreatedIndex       = "Organization~created"
+	orgInstitutionIndex   = "Organization~institution"
+	orgParentIndex        = "Organization~parent"
+	orgPubKeyIndex        = "OrgPubKey~fingerprint"
+	orgCreditLogIndex     = "OrgCreditLog~org~credit~ts"
+	orgCreditLogDescIndex = "OrgCreditLog~org~credit~tsdesc"
+)
+
+var indexValue = []byte{0x00}
+
+type IndexRebuildResult struct {
+	Orgs       int `json:"orgs"`
+	OrgKeys    int `json:"orgKeys"`
+	CreditLogs int `json:"creditLogs"`
+}
+
+// sortableTimestamp pads a timestamp so that keys sort in timestamp order
+func sortableTimestamp(ts int64) string {
+	return fmt.Sprintf("%020d", ts)
+}
+
+// publicKeyFingerprint is the hex sha256 of the DER encoded public key, so
+// differently formatted PEMs of the same key share a fingerprint
+func publicKeyFingerprint(pubKey *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// RebuildIndexes writes the indexes of orgs and credit logs stored before the
+// indexes existed
+func (s *SmartContract) RebuildIndexes(ctx contractapi.TransactionContextInterface) (*IndexRebuildResult, error) {
+	result := &IndexRebuildResult{}
+	orgsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("Organization", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer orgsIterator.Close()
+	for orgsIterator.HasNext() {
+		queryResult, err := orgsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var org Organization
+		if err = json.Unmarshal(queryResult.Value, &org); err != nil {
+			return nil, err
+		}
+		if err = s.putOrgIndexes(ctx.GetStub(), &org); err != nil {
+			return nil, err
+		}
+		result.Orgs++
+		if org.PubKeyPem != "" {
+			result.OrgKeys++
+		}
+	}
+	logsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("OrganizationCreditLog", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer logsIterator.Close()
+	for logsIterator.HasNext() {
+		queryResult, err := logsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var orgCreditLog OrgCreditLog
+		if err = json.Unmarshal(queryResult.Value, &orgCreditLog); err != nil {
+			return nil, err
+		}
+		if err = s.putOrgCreditLogIndexes(ctx.GetStub(), &orgCreditLog); err != nil {
+			return nil, err
+		}
+		result.CreditLogs++
+	}
+	return result, nil
+}
+
+// putOrgIndexes writes every index of an org. Archived orgs are left out of
+// the created index so that ListOrgs skips them
+func (s *SmartContract) putOrgIndexes(stub shim.ChaincodeStubInterface, org *Organization) error {
+	if org.ArchivedAt == 0 {
+		createdId, err := s.newOrgCreatedIndexId(stub, org.CreateTxTimestamp, org.ID)
+		if err != nil {
+			return err
+		}
+		if err = stub.PutState(createdId, indexValue); err != nil {
+			return err
+		}
+	}
+	if err := s.putOrgInstitutionIndex(stub, org.ID, org.InstitutionID); err != nil {
+		return err
+	}
+	if err := s.putOrgParentIndex(stub, org.ID, org.ParentOrgID); err != nil {
+		return err
+	}
+	if org.PubKeyPem == "" {
+		return nil
+	}
+	return s.putOrgPubKeyIndex(stub, org.ID, org.PubKeyType, org.PubKeyPem)
+}
+
+func (s *SmartContract) putOrgInstitutionIndex(stub shim.ChaincodeStubInterface, orgId string, institutionId string) error {
+	if institutionId == "" {
+		return nil
+	}
+	indexId, err := s.newOrgInstitutionIndexId(stub, institutionId, orgId)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(indexId, indexValue)
+}
+
+// moveOrgInstitutionIndex re-indexes an org whose institution id changed
+func (s *SmartContract) moveOrgInstitutionIndex(stub shim.ChaincodeStubInterface, orgId string, oldInstitutionId string, newInstitutionId string) error {
+	if oldInstitutionId == newInstitutionId {
+		return nil
+	}
+	if oldInstitutionId != "" {
+		oldId, err := s.newOrgInstitutionIndexId(stub, oldInstitutionId, orgId)
+		if err != nil {
+			return err
+		}
+		if err = stub.DelState(oldId); err != nil {
+			return err
+		}
+	}
+	return s.putOrgInstitutionIndex(stub, orgId, newInstitutionId)
+}
+
+func (s *SmartContract) putOrgParentIndex(stub shim.ChaincodeStubInterface, orgId string, parentOrgId string) error {
+	if parentOrgId == "" {
+		return nil
+	}
+	indexId, err := s.newOrgParentIndexId(stub, parentOrgId, orgId)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(indexId, indexValue)
+}
+
+func (s *SmartContract) delOrgParentIndex(stub shim.ChaincodeStubInterface, orgId string, parentOrgId string) error {
+	if parentOrgId == "" {
+		return nil
+	}
+	indexId, err := s.newOrgParentIndexId(stub, parentOrgId, orgId)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(indexId)
+}
+
+func (s *SmartContract) putOrgPubKeyIndex(stub shim.ChaincodeStubInterface, orgId string, pubKeyType string, pubKeyPem string) error {
+	pubKeyId, err := s.orgPubKeyIndexId(stub, orgId, pubKeyType, pubKeyPem)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(pubKeyId, indexValue)
+}
+
+func (s *SmartContract) delOrgPubKeyIndex(stub shim.ChaincodeStubInterface, orgId string, pubKeyType string, pubKeyPem string) error {
+	pubKeyId, err := s.orgPubKeyIndexId(stub, orgId, pubKeyType, pubKeyPem)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(pubKeyId)
+}
+
+func (s *SmartContract) orgPubKeyIndexId(stub shim.ChaincodeStubInterface, orgId string, pubKeyType string, pubKeyPem string) (string, error) {
+	pubKey, err := parseOrgPublicKey(pubKeyType, pubKeyPem)
+	if err != nil {
+		return "", err
+	}
+	fingerprint, err := publicKeyFingerprint(pubKey)
+	if err != nil {
+		return "", err
+	}
+	return s.newOrgPubKeyIndexId(stub, fingerprint, orgId)
+}
+
+// orgIdsByPubKeyFingerprint returns the ids of the orgs registered with a key
+func (s *SmartContract) orgIdsByPubKeyFingerprint(stub shim.ChaincodeStubInterface, fingerprint string) ([]string, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(orgPubKeyIndex, []string{fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	orgIds := make([]string, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+		orgIds = append(orgIds, attributes[len(attributes)-1])
+	}
+	return orgIds, nil
+}
+
+func (s *SmartContract) putOrgCreditLogIndexes(stub shim.ChaincodeStubInterface, orgCreditLog *OrgCreditLog) error {
+	ascId, err := s.newOrgCreditLogIndexId(stub, orgCreditLog.OrgID, orgCreditLog.CreditID, orgCreditLog.TxTimestamp, orgCreditLog.ID)
+	if err != nil {
+		return err
+	}
+	if err = stub.PutState(ascId, indexValue); err != nil {
+		return err
+	}
+	descId, err := s.newOrgCreditLogDescIndexId(stub, orgCreditLog.OrgID, orgCreditLog.CreditID, orgCreditLog.TxTimestamp, orgCreditLog.ID)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(descId, indexValue)
+}
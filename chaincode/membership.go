@@ -0,0 +1,204 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// OrgMembership grants org roles to an identity without re-enrolling it with the CA
+type OrgMembership struct {
+	DocType           string   `json:"docType"`
+	OrgID             string   `json:"orgId"`
+	MSPID             string   `json:"mspId"`
+	IdentityHash      string   `json:"identityHash"`
+	Roles             []string `json:"roles"`
+	UpdatedBy         Actor    `json:"updatedBy" metadata:",optional"`
+	CreateTxTimestamp int64    `json:"createTxTimestamp"`
+	UpdateTxTimestamp int64    `json:"updateTxTimestamp"`
+}
+
+type ClientIdentity struct {
+	MSPID        string `json:"mspId"`
+	ClientID     string `json:"clientId"`
+	IdentityHash string `json:"identityHash"`
+}
+
+// identityHash hashes the certificate ID (cid.GetID) of an identity
+func identityHash(clientId string) string {
+	hash := sha256.Sum256([]byte(clientId))
+	return hex.EncodeToString(hash[:])
+}
+
+// GetMyIdentity returns the values an org admin needs to grant roles to the caller
+func (s *SmartContract) GetMyIdentity(ctx contractapi.TransactionContextInterface) (*ClientIdentity, error) {
+	mspId, clientId, err := getClientIdentity(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	return &ClientIdentity{
+		MSPID:        mspId,
+		ClientID:     clientId,
+		IdentityHash: identityHash(clientId),
+	}, nil
+}
+
+func (s *SmartContract) GrantOrgRole(ctx contractapi.TransactionContextInterface, orgId string, mspId string, identityHash string, role string) (*OrgMembership, error) {
+	if err := validateInput(&orgMemberInput{OrgID: orgId, MSPID: mspId, IdentityHash: identityHash, Role: role}); err != nil {
+		return nil, err
+	}
+	exists, err := s.OrgExists(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, newNotFoundError("Org %s does not exist", orgId)
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	membership, err := s.readOrgMembership(ctx.GetStub(), orgId, mspId, identityHash)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		membership = &OrgMembership{
+			DocType:           "OrgMembership",
+			OrgID:             orgId,
+			MSPID:             mspId,
+			IdentityHash:      identityHash,
+			Roles:             []string{},
+			CreateTxTimestamp: ts.AsTime().Unix(),
+		}
+	}
+	if containsString(membership.Roles, role) {
+		return nil, newAlreadyExistsError("Identity already has role %s on org %s", role, orgId)
+	}
+	membership.Roles = append(membership.Roles, role)
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	membership.UpdatedBy = actor
+	membership.UpdateTxTimestamp = ts.AsTime().Unix()
+	if err = s.putOrgMembership(ctx.GetStub(), membership); err != nil {
+		return nil, err
+	}
+	event := events.OrgRole{OrgID: orgId, MSPID: mspId, IdentityHash: identityHash, Role: role}
+	if err = s.emitEvent(ctx, events.OrgRoleGranted, event); err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+func (s *SmartContract) RevokeOrgRole(ctx contractapi.TransactionContextInterface, orgId string, mspId string, identityHash string, role string) error {
+	if err := validateInput(&orgMemberInput{OrgID: orgId, MSPID: mspId, IdentityHash: identityHash, Role: role}); err != nil {
+		return err
+	}
+	membership, err := s.readOrgMembership(ctx.GetStub(), orgId, mspId, identityHash)
+	if err != nil {
+		return err
+	}
+	if membership == nil || !containsString(membership.Roles, role) {
+		return newNotFoundError("Identity does not have role %s on org %s", role, orgId)
+	}
+	roles := make([]string, 0, len(membership.Roles))
+	for _, r := range membership.Roles {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	stateId, err := s.newOrgMembershipStateId(ctx.GetStub(), orgId, mspId, identityHash)
+	if err != nil {
+		return err
+	}
+	event := events.OrgRole{OrgID: orgId, MSPID: mspId, IdentityHash: identityHash, Role: role}
+	if len(roles) == 0 {
+		if err = ctx.GetStub().DelState(stateId); err != nil {
+			return err
+		}
+		return s.emitEvent(ctx, events.OrgRoleRevoked, event)
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	membership.Roles = roles
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	membership.UpdatedBy = actor
+	membership.UpdateTxTimestamp = ts.AsTime().Unix()
+	if err = s.putOrgMembership(ctx.GetStub(), membership); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, events.OrgRoleRevoked, event)
+}
+
+func (s *SmartContract) ListOrgMembers(ctx contractapi.TransactionContextInterface, orgId string) ([]*OrgMembership, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("OrgMembership", []string{orgId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var memberships []*OrgMembership = make([]*OrgMembership, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var membership OrgMembership
+		if err = json.Unmarshal(queryResult.Value, &membership); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &membership)
+	}
+	return memberships, nil
+}
+
+func (s *SmartContract) readOrgMembership(stub shim.ChaincodeStubInterface, orgId string, mspId string, identityHash string) (*OrgMembership, error) {
+	stateId, err := s.newOrgMembershipStateId(stub, orgId, mspId, identityHash)
+	if err != nil {
+		return nil, err
+	}
+	membershipJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	if membershipJSON == nil {
+		return nil, nil
+	}
+	var membership OrgMembership
+	if err = json.Unmarshal(membershipJSON, &membership); err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (s *SmartContract) putOrgMembership(stub shim.ChaincodeStubInterface, membership *OrgMembership) error {
+	stateId, err := s.newOrgMembershipStateId(stub, membership.OrgID, membership.MSPID, membership.IdentityHash)
+	if err != nil {
+		return err
+	}
+	membershipJSON, err := json.Marshal(membership)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(stateId, membershipJSON)
+}
+
+// readMyOrgMembership returns the registry entry of the submitting identity on an org
+func (s *SmartContract) readMyOrgMembership(stub shim.ChaincodeStubInterface, orgId string) (*OrgMembership, error) {
+	mspId, clientId, err := getClientIdentity(stub)
+	if err != nil {
+		return nil, err
+	}
+	return s.readOrgMembership(stub, orgId, mspId, identityHash(clientId))
+}
@@ -0,0 +1,58 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrgRoleRegistry(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG1")
+	registrar := newIdentity(t, "Org1MSP", "registrar", nil)
+	me, err := sc.GetMyIdentity(l.as(registrar))
+	require.NoError(t, err)
+
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, sc.IdentityHasRoleOnOrg(l.as(registrar), "ORG1", "admin"))
+
+	_, err = sc.GrantOrgRole(l.as(su), "ORG1", me.MSPID, me.IdentityHash, "admin")
+	require.NoError(t, err)
+	_, err = sc.GrantOrgRole(l.as(su), "ORG1", me.MSPID, me.IdentityHash, "admin")
+	requireErrorCode(t, chaincode.ErrorCodeAlreadyExists, err)
+	membership, err := sc.GrantOrgRole(l.as(su), "ORG1", me.MSPID, me.IdentityHash, "member")
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin", "member"}, membership.Roles)
+
+	require.NoError(t, sc.IdentityHasRoleOnOrg(l.as(registrar), "ORG1", "admin"))
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, sc.IdentityHasRoleOnOrg(l.as(registrar), "ORG2", "admin"))
+	// a registry admin passes the permission matrix without a certificate attribute
+	require.NoError(t, l.call(registrar, "GrantOrgRole", "ORG1"))
+
+	require.NoError(t, sc.RevokeOrgRole(l.as(su), "ORG1", me.MSPID, me.IdentityHash, "admin"))
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, sc.IdentityHasRoleOnOrg(l.as(registrar), "ORG1", "admin"))
+	require.NoError(t, sc.IdentityHasOrgID(l.as(registrar), "ORG1"))
+	members, err := sc.ListOrgMembers(l.as(su), "ORG1")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.Equal(t, []string{"member"}, members[0].Roles)
+
+	require.NoError(t, sc.RevokeOrgRole(l.as(su), "ORG1", me.MSPID, me.IdentityHash, "member"))
+	requireErrorCode(t, chaincode.ErrorCodeNotFound, sc.RevokeOrgRole(l.as(su), "ORG1", me.MSPID, me.IdentityHash, "member"))
+	members, err = sc.ListOrgMembers(l.as(su), "ORG1")
+	require.NoError(t, err)
+	require.Empty(t, members)
+}
+
+func TestOrgRoleFromCertificate(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG1")
+	admin := newOrgAdmin(t, "ORG1")
+
+	require.NoError(t, sc.IdentityHasRoleOnOrg(l.as(admin), "ORG1", "admin"))
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, sc.IdentityHasRoleOnOrg(l.as(admin), "ORG1", "registrar"))
+}
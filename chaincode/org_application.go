@@ -0,0 +1,289 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/query"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	OrgApplicationStatusPending  = "pending"
+	OrgApplicationStatusApproved = "approved"
+	OrgApplicationStatusRejected = "rejected"
+)
+
+// OrgApplicationInput is the org proposed by an applicant. The key becomes the
+// org public key when the application is approved
+type OrgApplicationInput struct {
+	OrgID           string `json:"orgId" validate:"required,id"`
+	Name            string `json:"name" validate:"required,max=256"`
+	Desc            string `json:"desc,omitempty" metadata:",optional" validate:"max=2048"`
+	Email           string `json:"email,omitempty" metadata:",optional" validate:"omitempty,email,max=256"`
+	InstitutionID   string `json:"institutionId,omitempty" metadata:",optional" validate:"max=64"`
+	InstitutionName string `json:"institutionName,omitempty" metadata:",optional" validate:"max=256"`
+	LogoUrl         string `json:"logoUrl,omitempty" metadata:",optional" validate:"omitempty,http_url,max=2048"`
+	PubKeyType      string `json:"pubKeyType" validate:"required,oneof=ecdsa:P-384"`
+	PubKeyPem       string `json:"pubKeyPem" validate:"required,max=4096"`
+}
+
+type OrgApplication struct {
+	DocType           string              `json:"docType"`
+	ID                string              `json:"id"`
+	Status            string              `json:"status"`
+	Org               OrgApplicationInput `json:"org"`
+	Applicant         Actor               `json:"applicant"`
+	ReviewedBy        Actor               `json:"reviewedBy" metadata:",optional"`
+	Reason            string              `json:"reason"`
+	CreateTxTimestamp int64               `json:"createTxTimestamp"`
+	UpdateTxTimestamp int64               `json:"updateTxTimestamp"`
+}
+
+// SubmitOrgApplication lets a diplom-mn client apply for a new org, a super
+// admin approves or rejects it
+func (s *SmartContract) SubmitOrgApplication(ctx contractapi.TransactionContextInterface, input OrgApplicationInput) (*OrgApplication, error) {
+	isClient, err := s.IsDiplomMNClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !isClient {
+		return nil, newPermissionDeniedError("Insufficient Permission - only diplom-mn clients can apply")
+	}
+	if err = validateInput(&input); err != nil {
+		return nil, err
+	}
+	orgExists, err := s.OrgExists(ctx, input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if orgExists {
+		return nil, newAlreadyExistsError("Org %s already exists", input.OrgID)
+	}
+	pubKey, err := parseOrgPublicKey(input.PubKeyType, input.PubKeyPem)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := publicKeyFingerprint(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	orgIds, err := s.orgIdsByPubKeyFingerprint(ctx.GetStub(), fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if len(orgIds) > 0 {
+		return nil, newAlreadyExistsError("Public key is already taken")
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	applicant, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	application := &OrgApplication{
+		DocType:           "OrgApplication",
+		ID:                ctx.GetStub().GetTxID(),
+		Status:            OrgApplicationStatusPending,
+		Org:               input,
+		Applicant:         applicant,
+		CreateTxTimestamp: ts.AsTime().Unix(),
+		UpdateTxTimestamp: ts.AsTime().Unix(),
+	}
+	if err = s.putOrgApplication(ctx.GetStub(), application); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.OrgApplicationSubmitted, newOrgApplicationEvent(application)); err != nil {
+		return nil, err
+	}
+	return application, nil
+}
+
+// ApproveOrgApplication creates the org, its credit and key of a pending
+// application in one transaction
+func (s *SmartContract) ApproveOrgApplication(ctx contractapi.TransactionContextInterface, id string) (*OrgApplication, error) {
+	if err := validateInput(&referenceInput{ID: id}); err != nil {
+		return nil, err
+	}
+	application, err := s.readPendingOrgApplication(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.assertDirectOperationAllowed(ctx.GetStub(), OperationOrgKeySet); err != nil {
+		return nil, err
+	}
+	_, err = s.createOrg(ctx, &CreateOrgInput{
+		OrgID:           application.Org.OrgID,
+		Name:            application.Org.Name,
+		Desc:            application.Org.Desc,
+		Email:           application.Org.Email,
+		InstitutionID:   application.Org.InstitutionID,
+		InstitutionName: application.Org.InstitutionName,
+		LogoUrl:         application.Org.LogoUrl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.setOrgPublicKey(ctx, application.Org.OrgID, application.Org.PubKeyType, application.Org.PubKeyPem); err != nil {
+		return nil, err
+	}
+	if err = s.reviewOrgApplication(ctx, application, OrgApplicationStatusApproved, ""); err != nil {
+		return nil, err
+	}
+	return application, nil
+}
+
+func (s *SmartContract) RejectOrgApplication(ctx contractapi.TransactionContextInterface, id string, reason string) (*OrgApplication, error) {
+	if err := validateInput(&referenceInput{ID: id, Reason: reason}); err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		return nil, newValidationError("reason is required")
+	}
+	application, err := s.readPendingOrgApplication(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.reviewOrgApplication(ctx, application, OrgApplicationStatusRejected, reason); err != nil {
+		return nil, err
+	}
+	return application, nil
+}
+
+// ReadOrgApplication returns an application to its applicant or a super admin
+func (s *SmartContract) ReadOrgApplication(ctx contractapi.TransactionContextInterface, id string) (*OrgApplication, error) {
+	if err := validateInput(&referenceInput{ID: id}); err != nil {
+		return nil, err
+	}
+	application, err := s.readOrgApplication(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	if s.IsIdentitySuperAdmin(ctx) == nil {
+		return application, nil
+	}
+	mspId, clientId, err := getClientIdentity(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	if application.Applicant.MSPID != mspId || application.Applicant.ID != clientId {
+		return nil, InsufficientPermissionError
+	}
+	return application, nil
+}
+
+// ListOrgApplications returns the org applications with status, newest
+// first. An empty status lists all of them
+func (s *SmartContract) ListOrgApplications(ctx contractapi.TransactionContextInterface, status string) ([]*OrgApplication, error) {
+	if err := validateInput(&orgApplicationStatusInput{Status: status}); err != nil {
+		return nil, err
+	}
+	q := query.New().Where("docType", "OrgApplication")
+	if status == "" {
+		q.UseIndex("org-application-index-2", "org-application-index-2")
+	} else {
+		q.Where("status", status).UseIndex("org-application-index-1", "org-application-index-1")
+	}
+	queryString, err := q.Sort("createTxTimestamp", query.Desc).String()
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var applications []*OrgApplication = make([]*OrgApplication, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var application OrgApplication
+		if err = json.Unmarshal(queryResult.Value, &application); err != nil {
+			return nil, err
+		}
+		applications = append(applications, &application)
+	}
+	return applications, nil
+}
+
+func (s *SmartContract) reviewOrgApplication(ctx contractapi.TransactionContextInterface, application *OrgApplication, status string, reason string) error {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	reviewer, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	application.Status = status
+	application.Reason = reason
+	application.ReviewedBy = reviewer
+	application.UpdateTxTimestamp = ts.AsTime().Unix()
+	if err = s.putOrgApplication(ctx.GetStub(), application); err != nil {
+		return err
+	}
+	eventType := events.OrgApplicationApproved
+	if status == OrgApplicationStatusRejected {
+		eventType = events.OrgApplicationRejected
+	}
+	return s.emitEvent(ctx, eventType, newOrgApplicationEvent(application))
+}
+
+func (s *SmartContract) readOrgApplication(stub shim.ChaincodeStubInterface, id string) (*OrgApplication, error) {
+	stateId, err := s.newOrgApplicationStateId(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	applicationJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	if applicationJSON == nil {
+		return nil, newNotFoundError("Org application %s does not exist", id)
+	}
+	var application OrgApplication
+	if err = json.Unmarshal(applicationJSON, &application); err != nil {
+		return nil, err
+	}
+	return &application, nil
+}
+
+func (s *SmartContract) readPendingOrgApplication(stub shim.ChaincodeStubInterface, id string) (*OrgApplication, error) {
+	application, err := s.readOrgApplication(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	if application.Status != OrgApplicationStatusPending {
+		return nil, newConflictError("Org application %s is %s", id, application.Status)
+	}
+	return application, nil
+}
+
+func (s *SmartContract) putOrgApplication(stub shim.ChaincodeStubInterface, application *OrgApplication) error {
+	stateId, err := s.newOrgApplicationStateId(stub, application.ID)
+	if err != nil {
+		return err
+	}
+	applicationJSON, err := json.Marshal(application)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(stateId, applicationJSON)
+}
+
+func newOrgApplicationEvent(application *OrgApplication) events.OrgApplication {
+	return events.OrgApplication{
+		ID:             application.ID,
+		OrgID:          application.Org.OrgID,
+		Name:           application.Org.Name,
+		Status:         application.Status,
+		Reason:         application.Reason,
+		ApplicantMSPID: application.Applicant.MSPID,
+	}
+}
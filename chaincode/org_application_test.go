@@ -0,0 +1,33 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListOrgApplicationsStatusFilter(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	var queries []string
+	l.stub.GetQueryResultStub = func(query string) (shim.StateQueryIteratorInterface, error) {
+		queries = append(queries, query)
+		return newIterator(nil, l.state), nil
+	}
+
+	_, err := sc.ListOrgApplications(l.as(su), "")
+	require.NoError(t, err)
+	_, err = sc.ListOrgApplications(l.as(su), chaincode.OrgApplicationStatusRejected)
+	require.NoError(t, err)
+	_, err = sc.ListOrgApplications(l.as(su), "expired")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+
+	require.Len(t, queries, 2)
+	require.NotContains(t, queries[0], `"status"`)
+	require.Contains(t, queries[0], "org-application-index-2")
+	require.Contains(t, queries[1], `"status":{"$eq":"rejected"}`)
+	require.Contains(t, queries[1], "org-application-index-1")
+}
@@ -0,0 +1,250 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// OrgTrust records that OrgID accepts TrustedOrgID as a co-signer for Purpose
+// between ValidFrom and ValidTo
+type OrgTrust struct {
+	DocType           string `json:"docType"`
+	ID                string `json:"id"`
+	OrgID             string `json:"orgId"`
+	TrustedOrgID      string `json:"trustedOrgId"`
+	Purpose           string `json:"purpose"`
+	ValidFrom         int64  `json:"validFrom"`
+	ValidTo           int64  `json:"validTo"`
+	Revoked           bool   `json:"revoked"`
+	UpdatedBy         Actor  `json:"updatedBy" metadata:",optional"`
+	CreateTxTimestamp int64  `json:"createTxTimestamp"`
+	UpdateTxTimestamp int64  `json:"updateTxTimestamp"`
+}
+
+// TrustOrg lets the admins of orgId accept trustedOrgId as a co-signer for purpose
+func (s *SmartContract) TrustOrg(ctx contractapi.TransactionContextInterface, orgId string, trustedOrgId string, purpose string, validFrom int64, validTo int64) (*OrgTrust, error) {
+	if err := validateInput(&orgTrustInput{OrgID: orgId, TrustedOrgID: trustedOrgId, Purpose: purpose, ValidFrom: validFrom, ValidTo: validTo}); err != nil {
+		return nil, err
+	}
+	exists, err := s.OrgExists(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, newNotFoundError("Org %s does not exist", orgId)
+	}
+	trustedOrg, err := s.readOrg(ctx.GetStub(), trustedOrgId)
+	if err != nil {
+		return nil, err
+	}
+	if err = assertOrgNotArchived(trustedOrg); err != nil {
+		return nil, err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if validTo <= ts.AsTime().Unix() {
+		return nil, newValidationError("validTo should be in the future")
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	trust := &OrgTrust{
+		DocType:           "OrgTrust",
+		ID:                ctx.GetStub().GetTxID(),
+		OrgID:             orgId,
+		TrustedOrgID:      trustedOrgId,
+		Purpose:           purpose,
+		ValidFrom:         validFrom,
+		ValidTo:           validTo,
+		UpdatedBy:         actor,
+		CreateTxTimestamp: ts.AsTime().Unix(),
+		UpdateTxTimestamp: ts.AsTime().Unix(),
+	}
+	if err = s.putOrgTrust(ctx.GetStub(), trust); err != nil {
+		return nil, err
+	}
+	if err = s.putOrgTrustIndexes(ctx.GetStub(), trust); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.OrgTrustGranted, newOrgTrustEvent(trust)); err != nil {
+		return nil, err
+	}
+	return trust, nil
+}
+
+func (s *SmartContract) RevokeOrgTrust(ctx contractapi.TransactionContextInterface, orgId string, id string) error {
+	if err := validateInput(&referenceInput{ID: id}); err != nil {
+		return err
+	}
+	trust, err := s.readOrgTrust(ctx.GetStub(), id)
+	if err != nil {
+		return err
+	}
+	if trust.OrgID != orgId {
+		return newNotFoundError("Trust %s does not belong to org %s", id, orgId)
+	}
+	if trust.Revoked {
+		return newConflictError("Trust %s is already revoked", id)
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	trust.Revoked = true
+	trust.UpdatedBy = actor
+	trust.UpdateTxTimestamp = ts.AsTime().Unix()
+	if err = s.putOrgTrust(ctx.GetStub(), trust); err != nil {
+		return err
+	}
+	if err = s.delOrgTrustIndexes(ctx.GetStub(), trust); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, events.OrgTrustRevoked, newOrgTrustEvent(trust))
+}
+
+// ListOrgTrusts lists the trusts given by an org that have not been revoked
+func (s *SmartContract) ListOrgTrusts(ctx contractapi.TransactionContextInterface, orgId string) ([]*OrgTrust, error) {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return nil, err
+	}
+	return s.orgTrusts(ctx.GetStub(), []string{orgId})
+}
+
+// IsTrustedCoSigner tells whether issuerOrgId currently accepts coSignerOrgId
+// as a co-signer for purpose
+func (s *SmartContract) IsTrustedCoSigner(ctx contractapi.TransactionContextInterface, issuerOrgId string, coSignerOrgId string, purpose string) (bool, error) {
+	for _, id := range []string{issuerOrgId, coSignerOrgId, purpose} {
+		if err := validateInput(&referenceInput{ID: id}); err != nil {
+			return false, err
+		}
+	}
+	return s.isTrustedCoSigner(ctx.GetStub(), issuerOrgId, coSignerOrgId, purpose)
+}
+
+func (s *SmartContract) isTrustedCoSigner(stub shim.ChaincodeStubInterface, issuerOrgId string, coSignerOrgId string, purpose string) (bool, error) {
+	trusts, err := s.orgTrusts(stub, []string{issuerOrgId, coSignerOrgId, purpose})
+	if err != nil {
+		return false, err
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return false, err
+	}
+	now := ts.AsTime().Unix()
+	for _, trust := range trusts {
+		if trust.ValidFrom <= now && now < trust.ValidTo {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *SmartContract) orgTrusts(stub shim.ChaincodeStubInterface, keys []string) ([]*OrgTrust, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("OrgTrust~trusted", keys)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	trusts := make([]*OrgTrust, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+		trust, err := s.readOrgTrust(stub, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		trusts = append(trusts, trust)
+	}
+	return trusts, nil
+}
+
+func (s *SmartContract) readOrgTrust(stub shim.ChaincodeStubInterface, id string) (*OrgTrust, error) {
+	stateId, err := s.newOrgTrustStateId(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	trustJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	if trustJSON == nil {
+		return nil, newNotFoundError("Trust %s does not exist", id)
+	}
+	var trust OrgTrust
+	if err = json.Unmarshal(trustJSON, &trust); err != nil {
+		return nil, err
+	}
+	return &trust, nil
+}
+
+func (s *SmartContract) putOrgTrust(stub shim.ChaincodeStubInterface, trust *OrgTrust) error {
+	stateId, err := s.newOrgTrustStateId(stub, trust.ID)
+	if err != nil {
+		return err
+	}
+	trustJSON, err := json.Marshal(trust)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(stateId, trustJSON)
+}
+
+func newOrgTrustEvent(trust *OrgTrust) events.OrgTrust {
+	return events.OrgTrust{
+		ID:           trust.ID,
+		OrgID:        trust.OrgID,
+		TrustedOrgID: trust.TrustedOrgID,
+		Purpose:      trust.Purpose,
+		ValidFrom:    trust.ValidFrom,
+		ValidTo:      trust.ValidTo,
+	}
+}
+
+// putOrgTrustIndexes indexes a trust by the trusting org and, so that an org
+// can tell who trusts it, by the trusted org
+func (s *SmartContract) putOrgTrustIndexes(stub shim.ChaincodeStubInterface, trust *OrgTrust) error {
+	indexId, err := s.newOrgTrustIndexId(stub, trust.OrgID, trust.TrustedOrgID, trust.Purpose, trust.ID)
+	if err != nil {
+		return err
+	}
+	if err = stub.PutState(indexId, indexValue); err != nil {
+		return err
+	}
+	trusterId, err := s.newOrgTrustTrusterIndexId(stub, trust.TrustedOrgID, trust.OrgID, trust.Purpose, trust.ID)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(trusterId, indexValue)
+}
+
+func (s *SmartContract) delOrgTrustIndexes(stub shim.ChaincodeStubInterface, trust *OrgTrust) error {
+	indexId, err := s.newOrgTrustIndexId(stub, trust.OrgID, trust.TrustedOrgID, trust.Purpose, trust.ID)
+	if err != nil {
+		return err
+	}
+	if err = stub.DelState(indexId); err != nil {
+		return err
+	}
+	trusterId, err := s.newOrgTrustTrusterIndexId(stub, trust.TrustedOrgID, trust.OrgID, trust.Purpose, trust.ID)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(trusterId)
+}
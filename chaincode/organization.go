@@ -2,12 +2,15 @@ package chaincode
 
 import (
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
-	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -20,79 +23,184 @@ type Organization struct {
 	Email             string `json:"email"`
 	InstitutionID     string `json:"institutionId"`
 	InstitutionName   string `json:"institutionName"`
+	ParentOrgID       string `json:"parentOrgId,omitempty" metadata:",optional"`
 	Desc              string `json:"desc"`
 	OrgCreditID       string `json:"orgCreditId"`
 	LogoUrl           string `json:"logoUrl"`
 	IsActive          bool   `json:"isActive"`
 	PubKeyType        string `json:"pubKeyType"`
 	PubKeyPem         string `json:"pubKeyPem"`
+	CreatedBy         Actor  `json:"createdBy" metadata:",optional"`
+	UpdatedBy         Actor  `json:"updatedBy" metadata:",optional"`
 	CreateTxTimestamp int64  `json:"createTxTimestamp"`
 	UpdateTxTimestamp int64  `json:"updateTxTimestamp"`
+	ArchivedAt        int64  `json:"archivedAt,omitempty" metadata:",optional"`
+}
+
+const (
+	OrgStatusActive   = "active"
+	OrgStatusInactive = "inactive"
+	OrgStatusArchived = "archived"
+	OrgKeyPresent     = "present"
+	OrgKeyAbsent      = "absent"
+)
+
+// OrgFilter narrows ListOrgsPaginated. Empty fields do not filter, CreatedFrom
+// and CreatedTo are inclusive unix timestamps. Archived orgs are only listed
+// with the archived status
+type OrgFilter struct {
+	Status        string `json:"status"`
+	InstitutionID string `json:"institutionId"`
+	ParentOrgID   string `json:"parentOrgId"`
+	NamePrefix    string `json:"namePrefix"`
+	PubKey        string `json:"pubKey"`
+	CreatedFrom   int64  `json:"createdFrom"`
+	CreatedTo     int64  `json:"createdTo"`
+}
+
+// CreateOrgInput is the argument of CreateOrgWithInput. The credit is created
+// with InitialCredit, 0 when empty, and the org is active unless Status is inactive
+type CreateOrgInput struct {
+	OrgID           string `json:"orgId" validate:"required,id"`
+	Name            string `json:"name" validate:"required,max=256"`
+	Desc            string `json:"desc,omitempty" metadata:",optional" validate:"max=2048"`
+	Email           string `json:"email,omitempty" metadata:",optional" validate:"omitempty,email,max=256"`
+	InstitutionID   string `json:"institutionId,omitempty" metadata:",optional" validate:"max=64"`
+	InstitutionName string `json:"institutionName,omitempty" metadata:",optional" validate:"max=256"`
+	ParentOrgID     string `json:"parentOrgId,omitempty" metadata:",optional" validate:"omitempty,id"`
+	LogoUrl         string `json:"logoUrl,omitempty" metadata:",optional" validate:"omitempty,http_url,max=2048"`
+	InitialCredit   string `json:"initialCredit,omitempty" metadata:",optional" validate:"omitempty,decimal=2"`
+	CreditTitle     string `json:"creditTitle,omitempty" metadata:",optional" validate:"max=256"`
+	Status          string `json:"status,omitempty" metadata:",optional" validate:"omitempty,oneof=active inactive"`
+}
+
+// UpdateOrgInput is the argument of UpdateOrgWithInput. Only the fields that
+// are set change, Clear lists the optional fields to empty
+type UpdateOrgInput struct {
+	OrgID           string   `json:"orgId" validate:"required,id"`
+	Name            string   `json:"name,omitempty" metadata:",optional" validate:"max=256"`
+	Desc            string   `json:"desc,omitempty" metadata:",optional" validate:"max=2048"`
+	Email           string   `json:"email,omitempty" metadata:",optional" validate:"omitempty,email,max=256"`
+	InstitutionID   string   `json:"institutionId,omitempty" metadata:",optional" validate:"max=64"`
+	InstitutionName string   `json:"institutionName,omitempty" metadata:",optional" validate:"max=256"`
+	LogoUrl         string   `json:"logoUrl,omitempty" metadata:",optional" validate:"omitempty,http_url,max=2048"`
+	Status          string   `json:"status,omitempty" metadata:",optional" validate:"omitempty,oneof=active inactive"`
+	Clear           []string `json:"clear,omitempty" metadata:",optional" validate:"dive,oneof=desc email institutionId institutionName logoUrl"`
+}
+
+type ListOrganization struct {
+	BookMark string          `json:"bookMark" validate:"required"`
+	Records  []*Organization `json:"records" validate:"required"`
 }
 
 func (s *SmartContract) OrgExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	orgId, err := s.newOrgStateId(ctx.GetStub(), id)
 	if err != nil {
-		return false, fmt.Errorf("Org ID error - %s", err)
+		return false, newValidationError("Org ID error - %s", err)
 	}
 	org, err := ctx.GetStub().GetState(orgId)
 	if err != nil {
-		return false, fmt.Errorf("Failed to retrieve org - %s", err)
+		return false, newInternalError("Failed to retrieve org - %s", err)
 	}
 	return org != nil, nil
 }
 
 func (s *SmartContract) CreateOrg(ctx contractapi.TransactionContextInterface, orgId string, name string, desc string, institutionId string, institutionName, logo string, initialCredit string, creditDesc string, isActive bool) error {
-	err := s.IsIdentitySuperAdmin(ctx)
-	if err != nil {
-		return err
+	status := OrgStatusActive
+	if !isActive {
+		status = OrgStatusInactive
+	}
+	_, err := s.createOrg(ctx, &CreateOrgInput{
+		OrgID:           orgId,
+		Name:            name,
+		Desc:            desc,
+		InstitutionID:   institutionId,
+		InstitutionName: institutionName,
+		LogoUrl:         logo,
+		InitialCredit:   initialCredit,
+		CreditTitle:     desc,
+		Status:          status,
+	})
+	return err
+}
+
+// CreateOrgWithInput creates an org from a single typed argument
+func (s *SmartContract) CreateOrgWithInput(ctx contractapi.TransactionContextInterface, input CreateOrgInput) (*Organization, error) {
+	return s.createOrg(ctx, &input)
+}
+
+func (s *SmartContract) createOrg(ctx contractapi.TransactionContextInterface, input *CreateOrgInput) (*Organization, error) {
+	if err := validateInput(input); err != nil {
+		return nil, err
 	}
-	orgExists, err := s.OrgExists(ctx, orgId)
+	orgExists, err := s.OrgExists(ctx, input.OrgID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if orgExists {
-		return fmt.Errorf("Org %s already exists", orgId)
+		return nil, newAlreadyExistsError("Org %s already exists", input.OrgID)
 	}
-	orgStateId, err := s.newOrgStateId(ctx.GetStub(), orgId)
+	if input.ParentOrgID != "" {
+		if err = s.assertOrgParentAllowed(ctx.GetStub(), input.OrgID, input.ParentOrgID); err != nil {
+			return nil, err
+		}
+	}
+	orgStateId, err := s.newOrgStateId(ctx.GetStub(), input.OrgID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	ts, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	initialCredit := input.InitialCredit
+	if initialCredit == "" {
+		initialCredit = "0"
 	}
-	orgCredit, err := s.CreateCredit(ctx, orgId, desc, initialCredit)
+	orgCredit, err := s.CreateCredit(ctx, input.OrgID, input.CreditTitle, initialCredit)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
 	}
 	org := Organization{
 		DocType:           "Organization",
-		ID:                orgId,
-		Name:              name,
-		Desc:              desc,
-		InstitutionID:     institutionId,
-		InstitutionName:   institutionName,
+		ID:                input.OrgID,
+		Name:              input.Name,
+		Email:             input.Email,
+		Desc:              input.Desc,
+		InstitutionID:     input.InstitutionID,
+		InstitutionName:   input.InstitutionName,
+		ParentOrgID:       input.ParentOrgID,
 		OrgCreditID:       orgCredit.ID,
-		LogoUrl:           logo,
-		IsActive:          isActive,
+		LogoUrl:           input.LogoUrl,
+		IsActive:          input.Status != OrgStatusInactive,
 		PubKeyType:        "",
 		PubKeyPem:         "",
+		CreatedBy:         actor,
+		UpdatedBy:         actor,
 		CreateTxTimestamp: ts.AsTime().UTC().Unix(),
 	}
 	orgJSON, err := json.Marshal(org)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err = ctx.GetStub().PutState(orgStateId, orgJSON); err != nil {
-		return err
+		return nil, err
+	}
+	if err = s.putOrgIndexes(ctx.GetStub(), &org); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.OrgCreated, newOrgEvent(&org)); err != nil {
+		return nil, err
 	}
-	return nil
+	return &org, nil
 }
 
 func (s *SmartContract) UpdateOrg(ctx contractapi.TransactionContextInterface, orgId string, name string, desc string, email string, institutionId string, institutionName, logo string, initialCredit string, creditDesc string, isActive bool, pubKeyType string, pubKeyPem string) error {
-	err := s.IsIdentitySuperAdmin(ctx)
-	if err != nil {
+	if err := validateInput(&orgInput{OrgID: orgId, Name: name, Desc: desc, Email: email, InstitutionID: institutionId, InstitutionName: institutionName, LogoUrl: logo}); err != nil {
 		return err
 	}
 	orgExists, err := s.OrgExists(ctx, orgId)
@@ -100,29 +208,96 @@ func (s *SmartContract) UpdateOrg(ctx contractapi.TransactionContextInterface, o
 		return err
 	}
 	if !orgExists {
-		return fmt.Errorf("Org %s does not exist", orgId)
+		return newNotFoundError("Org %s does not exist", orgId)
 	}
-	orgStateId, err := s.newOrgStateId(ctx.GetStub(), orgId)
+	org, err := s.ReadOrg(ctx, orgId)
 	if err != nil {
 		return err
 	}
-	ts, err := ctx.GetStub().GetTxTimestamp()
-	if err != nil {
+	if err = assertOrgNotArchived(org); err != nil {
 		return err
 	}
-	org, err := s.ReadOrg(ctx, orgId)
-	if err != nil {
-		return err
+	if org.IsActive && !isActive {
+		if err := s.assertDirectOperationAllowed(ctx.GetStub(), OperationOrgDeactivate); err != nil {
+			return err
+		}
 	}
+	oldInstitutionId := org.InstitutionID
 	org.Name = name
 	org.Desc = desc
 	org.Email = email
 	org.InstitutionID = institutionId
 	org.InstitutionName = institutionName
-	org.InstitutionID = institutionId
-	org.InstitutionID = institutionId
 	org.LogoUrl = logo
 	org.IsActive = isActive
+	return s.updateOrg(ctx, org, oldInstitutionId, events.OrgUpdated)
+}
+
+// UpdateOrgWithInput patches an org from a single typed argument
+func (s *SmartContract) UpdateOrgWithInput(ctx contractapi.TransactionContextInterface, input UpdateOrgInput) (*Organization, error) {
+	if err := validateInput(&input); err != nil {
+		return nil, err
+	}
+	org, err := s.readOrg(ctx.GetStub(), input.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	if err = assertOrgNotArchived(org); err != nil {
+		return nil, err
+	}
+	if org.IsActive && input.Status == OrgStatusInactive {
+		if err := s.assertDirectOperationAllowed(ctx.GetStub(), OperationOrgDeactivate); err != nil {
+			return nil, err
+		}
+	}
+	oldInstitutionId := org.InstitutionID
+	patches := []struct {
+		field string
+		value string
+		dest  *string
+	}{
+		{"name", input.Name, &org.Name},
+		{"desc", input.Desc, &org.Desc},
+		{"email", input.Email, &org.Email},
+		{"institutionId", input.InstitutionID, &org.InstitutionID},
+		{"institutionName", input.InstitutionName, &org.InstitutionName},
+		{"logoUrl", input.LogoUrl, &org.LogoUrl},
+	}
+	for _, patch := range patches {
+		if containsString(input.Clear, patch.field) {
+			if patch.value != "" {
+				return nil, newValidationError("%s is both set and cleared", patch.field)
+			}
+			*patch.dest = ""
+		} else if patch.value != "" {
+			*patch.dest = patch.value
+		}
+	}
+	if input.Status != "" {
+		org.IsActive = input.Status == OrgStatusActive
+	}
+	if err = s.updateOrg(ctx, org, oldInstitutionId, events.OrgUpdated); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// updateOrg stores a changed org and moves its institution index when the
+// institution changed
+func (s *SmartContract) updateOrg(ctx contractapi.TransactionContextInterface, org *Organization, oldInstitutionId string, eventType string) error {
+	orgStateId, err := s.newOrgStateId(ctx.GetStub(), org.ID)
+	if err != nil {
+		return err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	org.UpdatedBy = actor
 	org.UpdateTxTimestamp = ts.AsTime().UTC().Unix()
 	orgJSON, err := json.Marshal(org)
 	if err != nil {
@@ -131,12 +306,14 @@ func (s *SmartContract) UpdateOrg(ctx contractapi.TransactionContextInterface, o
 	if err = ctx.GetStub().PutState(orgStateId, orgJSON); err != nil {
 		return err
 	}
-	return nil
+	if err = s.moveOrgInstitutionIndex(ctx.GetStub(), org.ID, oldInstitutionId, org.InstitutionID); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, eventType, newOrgEvent(org))
 }
 
 func (s *SmartContract) UpdateMyOrg(ctx contractapi.TransactionContextInterface, orgId string, name string, email string, logo string) error {
-	err := s.IsIdentityAdminOfOrg(ctx, orgId)
-	if err != nil {
+	if err := validateInput(&orgPatchInput{OrgID: orgId, Name: name, Email: email, LogoUrl: logo}); err != nil {
 		return err
 	}
 	orgExists, err := s.OrgExists(ctx, orgId)
@@ -144,18 +321,13 @@ func (s *SmartContract) UpdateMyOrg(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 	if !orgExists {
-		return fmt.Errorf("Org %s does not exist", orgId)
-	}
-	orgStateId, err := s.newOrgStateId(ctx.GetStub(), orgId)
-	if err != nil {
-		return err
+		return newNotFoundError("Org %s does not exist", orgId)
 	}
-	ts, err := ctx.GetStub().GetTxTimestamp()
+	org, err := s.ReadOrg(ctx, orgId)
 	if err != nil {
 		return err
 	}
-	org, err := s.ReadOrg(ctx, orgId)
-	if err != nil {
+	if err = assertOrgNotArchived(org); err != nil {
 		return err
 	}
 	if email != "" {
@@ -167,32 +339,43 @@ func (s *SmartContract) UpdateMyOrg(ctx contractapi.TransactionContextInterface,
 	if name != "" {
 		org.Name = name
 	}
-	org.UpdateTxTimestamp = ts.AsTime().UTC().Unix()
-	orgJSON, err := json.Marshal(org)
-	if err != nil {
+	return s.updateOrg(ctx, org, org.InstitutionID, events.OrgUpdated)
+}
+
+func (s *SmartContract) SetOrgPublicKey(ctx contractapi.TransactionContextInterface, id string, pubKeyType string, pubKeyPemArg string) error {
+	if err := validateInput(&OrgKeyPayload{OrgID: id, PubKeyType: pubKeyType, PubKeyPem: pubKeyPemArg}); err != nil {
 		return err
 	}
-	if err = ctx.GetStub().PutState(orgStateId, orgJSON); err != nil {
+	if err := s.assertDirectOperationAllowed(ctx.GetStub(), OperationOrgKeySet); err != nil {
 		return err
 	}
-	return nil
+	return s.setOrgPublicKey(ctx, id, pubKeyType, pubKeyPemArg)
 }
 
-func (s *SmartContract) SetOrgPublicKey(ctx contractapi.TransactionContextInterface, id string, pubKeyType string, pubKeyPemArg string) error {
-	if err := s.IsIdentitySuperAdmin(ctx); err != nil {
-		return err
-	}
+func parseOrgPublicKey(pubKeyType string, pubKeyPem string) (*ecdsa.PublicKey, error) {
 	if pubKeyType != "ecdsa:P-384" {
-		return fmt.Errorf("Unsupported Pub key type")
+		return nil, newValidationError("Unsupported Pub key type")
+	}
+	pemBlock, _ := pem.Decode([]byte(pubKeyPem))
+	if pemBlock == nil {
+		return nil, newValidationError("Public key invalid")
 	}
-	pemBlock, _ := pem.Decode([]byte(pubKeyPemArg))
 	pubKey, err := x509.ParsePKIXPublicKey(pemBlock.Bytes)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, pubKeyOk := pubKey.(*ecdsa.PublicKey)
+	ecdsaPubKey, pubKeyOk := pubKey.(*ecdsa.PublicKey)
 	if !pubKeyOk {
-		return fmt.Errorf("Public key invalid")
+		return nil, newValidationError("Public key invalid")
+	}
+	return ecdsaPubKey, nil
+}
+
+// sets org public key without checking any permission
+func (s *SmartContract) setOrgPublicKey(ctx contractapi.TransactionContextInterface, id string, pubKeyType string, pubKeyPemArg string) error {
+	pubKey, err := parseOrgPublicKey(pubKeyType, pubKeyPemArg)
+	if err != nil {
+		return err
 	}
 	stateId, err := s.newOrgStateId(ctx.GetStub(), id)
 	if err != nil {
@@ -202,35 +385,46 @@ func (s *SmartContract) SetOrgPublicKey(ctx contractapi.TransactionContextInterf
 	if err != nil {
 		return err
 	}
+	if orgJSON == nil {
+		return newNotFoundError("Org %s does not exist", id)
+	}
 	var org Organization
 	err = json.Unmarshal(orgJSON, &org)
 	if err != nil {
 		return err
 	}
+	if err = assertOrgNotArchived(&org); err != nil {
+		return err
+	}
 
 	if len(org.PubKeyPem) > 0 {
-		return fmt.Errorf("Org already has a public key")
+		return newConflictError("Org already has a public key")
 	}
 
-	pubKeyQueryString := strings.ReplaceAll(pubKeyPemArg, "\n", "\\n")
-	queryString := fmt.Sprintf(`
-	{
-		"selector": {
-			"pubKeyPem":"%s"
-		}
-	}`, pubKeyQueryString)
-
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	fingerprint, err := publicKeyFingerprint(pubKey)
 	if err != nil {
 		return err
 	}
-	defer resultsIterator.Close()
-	if resultsIterator.HasNext() {
-		return fmt.Errorf("Public key is already taken")
+	orgIds, err := s.orgIdsByPubKeyFingerprint(ctx.GetStub(), fingerprint)
+	if err != nil {
+		return err
+	}
+	if len(orgIds) > 0 {
+		return newAlreadyExistsError("Public key is already taken")
 	}
 
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
 	org.PubKeyType = pubKeyType
 	org.PubKeyPem = pubKeyPemArg
+	org.UpdatedBy = actor
+	org.UpdateTxTimestamp = ts.AsTime().UTC().Unix()
 	updatedJSON, err := json.Marshal(org)
 	if err != nil {
 		return err
@@ -238,14 +432,24 @@ func (s *SmartContract) SetOrgPublicKey(ctx contractapi.TransactionContextInterf
 	if err := ctx.GetStub().PutState(stateId, updatedJSON); err != nil {
 		return err
 	}
-
-	return nil
+	if err := s.putOrgPubKeyIndex(ctx.GetStub(), org.ID, org.PubKeyType, org.PubKeyPem); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, events.OrgKeySet, newOrgEvent(&org))
 }
 
 func (s *SmartContract) RemoveOrgPublicKey(ctx contractapi.TransactionContextInterface, id string) error {
-	if err := s.IsIdentitySuperAdmin(ctx); err != nil {
+	if err := validateInput(&OrgStatusPayload{OrgID: id}); err != nil {
+		return err
+	}
+	if err := s.assertDirectOperationAllowed(ctx.GetStub(), OperationOrgKeyRemove); err != nil {
 		return err
 	}
+	return s.removeOrgPublicKey(ctx, id)
+}
+
+// removes org public key without checking any permission
+func (s *SmartContract) removeOrgPublicKey(ctx contractapi.TransactionContextInterface, id string) error {
 	stateId, err := s.newOrgStateId(ctx.GetStub(), id)
 	if err != nil {
 		return err
@@ -254,6 +458,9 @@ func (s *SmartContract) RemoveOrgPublicKey(ctx contractapi.TransactionContextInt
 	if err != nil {
 		return err
 	}
+	if orgJSON == nil {
+		return newNotFoundError("Org %s does not exist", id)
+	}
 	var org Organization
 	err = json.Unmarshal(orgJSON, &org)
 	if err != nil {
@@ -263,8 +470,18 @@ func (s *SmartContract) RemoveOrgPublicKey(ctx contractapi.TransactionContextInt
 	if err != nil {
 		return err
 	}
+	if org.PubKeyPem != "" {
+		if err = s.delOrgPubKeyIndex(ctx.GetStub(), org.ID, org.PubKeyType, org.PubKeyPem); err != nil {
+			return err
+		}
+	}
 	org.PubKeyType = ""
 	org.PubKeyPem = ""
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	org.UpdatedBy = actor
 	org.UpdateTxTimestamp = ts.AsTime().UTC().Unix()
 	updatedJSON, err := json.Marshal(org)
 	if err != nil {
@@ -273,11 +490,54 @@ func (s *SmartContract) RemoveOrgPublicKey(ctx contractapi.TransactionContextInt
 	if err := ctx.GetStub().PutState(stateId, updatedJSON); err != nil {
 		return err
 	}
+	return s.emitEvent(ctx, events.OrgKeyRemoved, newOrgEvent(&org))
+}
 
-	return nil
+// sets org active flag without checking any permission
+func (s *SmartContract) setOrgActive(ctx contractapi.TransactionContextInterface, id string, isActive bool) error {
+	exists, err := s.OrgExists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return newNotFoundError("Org %s does not exist", id)
+	}
+	stateId, err := s.newOrgStateId(ctx.GetStub(), id)
+	if err != nil {
+		return err
+	}
+	org, err := s.readOrg(ctx.GetStub(), id)
+	if err != nil {
+		return err
+	}
+	if err = assertOrgNotArchived(org); err != nil {
+		return err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	org.IsActive = isActive
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	org.UpdatedBy = actor
+	org.UpdateTxTimestamp = ts.AsTime().UTC().Unix()
+	updatedJSON, err := json.Marshal(org)
+	if err != nil {
+		return err
+	}
+	if err = ctx.GetStub().PutState(stateId, updatedJSON); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, events.OrgUpdated, newOrgEvent(org))
 }
 
 func (s *SmartContract) ReadOrg(ctx contractapi.TransactionContextInterface, id string) (*Organization, error) {
+	if err := validateInput(&referenceInput{ID: id}); err != nil {
+		return nil, err
+	}
 	return s.readOrg(ctx.GetStub(), id)
 }
 
@@ -290,6 +550,9 @@ func (s *SmartContract) readOrg(stub shim.ChaincodeStubInterface, id string) (*O
 	if err != nil {
 		return nil, err
 	}
+	if orgJSON == nil {
+		return nil, newNotFoundError("Org %s does not exist", id)
+	}
 	var org Organization
 	err = json.Unmarshal(orgJSON, &org)
 	if err != nil {
@@ -300,9 +563,13 @@ func (s *SmartContract) readOrg(stub shim.ChaincodeStubInterface, id string) (*O
 
 func (s *SmartContract) ReadMyOrg(ctx contractapi.TransactionContextInterface) (*Organization, error) {
 
-	orgId, orgIdFound, err := cid.GetAttributeValue(ctx.GetStub(), "diplom.mn.org.id")
+	config, err := s.readPlatformConfig(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	orgId, orgIdFound, err := cid.GetAttributeValue(ctx.GetStub(), config.OrgIDAttribute)
 	if !orgIdFound {
-		return nil, fmt.Errorf("diplom.mn.org.id not found in idendity")
+		return nil, newPermissionDeniedError("%s not found in idendity", config.OrgIDAttribute)
 	}
 
 	org, err := s.ReadOrg(ctx, orgId)
@@ -314,9 +581,13 @@ func (s *SmartContract) ReadMyOrg(ctx contractapi.TransactionContextInterface) (
 
 func (s *SmartContract) readMyOrg(stub shim.ChaincodeStubInterface) (*Organization, error) {
 
-	orgId, orgIdFound, err := cid.GetAttributeValue(stub, "diplom.mn.org.id")
+	config, err := s.readPlatformConfig(stub)
+	if err != nil {
+		return nil, err
+	}
+	orgId, orgIdFound, err := cid.GetAttributeValue(stub, config.OrgIDAttribute)
 	if !orgIdFound {
-		return nil, fmt.Errorf("diplom.mn.org.id not found in idendity")
+		return nil, newPermissionDeniedError("%s not found in idendity", config.OrgIDAttribute)
 	}
 
 	org, err := s.readOrg(stub, orgId)
@@ -326,45 +597,226 @@ func (s *SmartContract) readMyOrg(stub shim.ChaincodeStubInterface) (*Organizati
 	return org, nil
 }
 
+// GetOrgsByInstitution returns the orgs registered for an institution id
+func (s *SmartContract) GetOrgsByInstitution(ctx contractapi.TransactionContextInterface, institutionId string) ([]*Organization, error) {
+	if institutionId == "" {
+		return nil, newValidationError("institutionId is required")
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(orgInstitutionIndex, []string{institutionId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	return s.constructOrgsFromIndexIterator(ctx.GetStub(), resultsIterator)
+}
+
+// GetOrgByKeyFingerprint returns the org holding a public key. The fingerprint
+// is the hex sha256 of the DER encoded (SubjectPublicKeyInfo) public key
+func (s *SmartContract) GetOrgByKeyFingerprint(ctx contractapi.TransactionContextInterface, fingerprint string) (*Organization, error) {
+	fingerprint = strings.ToLower(fingerprint)
+	if decoded, err := hex.DecodeString(fingerprint); err != nil || len(decoded) != sha256.Size {
+		return nil, newValidationError("Invalid key fingerprint %s", fingerprint)
+	}
+	orgIds, err := s.orgIdsByPubKeyFingerprint(ctx.GetStub(), fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if len(orgIds) == 0 {
+		return nil, newNotFoundError("No org has key fingerprint %s", fingerprint)
+	}
+	return s.readOrg(ctx.GetStub(), orgIds[0])
+}
+
+// ListOrgs returns the orgs that are not archived in creation order. Until
+// RebuildIndexes has run on a ledger with orgs stored before the created index
+// existed, the index is empty and the orgs are read from their documents
 func (t *SmartContract) ListOrgs(ctx contractapi.TransactionContextInterface) ([]*Organization, error) {
-	queryString := fmt.Sprintf(`
-	{
-		"selector": {
-			"docType":"%s"
-		},
-		"sort": [
-			{
-				"createTxTimestamp": "asc"
-			}
-		]
-	}`, "Organization")
-	return getQueryResultForQueryStringFromOrg(ctx, queryString)
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(orgCreatedIndex, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	if !resultsIterator.HasNext() {
+		return t.listOrgsWithoutIndex(ctx.GetStub())
+	}
+	return t.constructOrgsFromIndexIterator(ctx.GetStub(), resultsIterator)
 }
 
-func getQueryResultForQueryStringFromOrg(ctx contractapi.TransactionContextInterface, queryString string) ([]*Organization, error) {
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+// listOrgsWithoutIndex scans every org document, it is only used before the
+// created index has been rebuilt
+func (s *SmartContract) listOrgsWithoutIndex(stub shim.ChaincodeStubInterface) ([]*Organization, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("Organization", []string{})
 	if err != nil {
 		return nil, err
 	}
 	defer resultsIterator.Close()
 
-	return constructQueryResponseFromIteratorFromOrg(resultsIterator)
+	var orgs []*Organization = make([]*Organization, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var org Organization
+		if err = json.Unmarshal(queryResult.Value, &org); err != nil {
+			return nil, err
+		}
+		if org.ArchivedAt == 0 {
+			orgs = append(orgs, &org)
+		}
+	}
+	sort.SliceStable(orgs, func(i, j int) bool {
+		return orgs[i].CreateTxTimestamp < orgs[j].CreateTxTimestamp
+	})
+	return orgs, nil
 }
 
-func constructQueryResponseFromIteratorFromOrg(resultsIterator shim.StateQueryIteratorInterface) ([]*Organization, error) {
+func (s *SmartContract) constructOrgsFromIndexIterator(stub shim.ChaincodeStubInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*Organization, error) {
 	var orgs []*Organization = make([]*Organization, 0)
 	for resultsIterator.HasNext() {
 		queryResult, err := resultsIterator.Next()
 		if err != nil {
 			return nil, err
 		}
-		var org Organization
-		err = json.Unmarshal(queryResult.Value, &org)
+		_, attributes, err := stub.SplitCompositeKey(queryResult.Key)
 		if err != nil {
 			return nil, err
 		}
-		orgs = append(orgs, &org)
+		org, err := s.readOrg(stub, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
 	}
-
 	return orgs, nil
 }
+
+// ListOrgsPaginated lists orgs matching filterJSON, a JSON encoded OrgFilter
+// or empty, in creation order. Orgs of an institution or a parent org are
+// listed in id order, as are archived orgs. Every page holds pageSize orgs
+// unless it is the last one, an empty BookMark marks the last page
+func (s *SmartContract) ListOrgsPaginated(ctx contractapi.TransactionContextInterface, filterJSON string, pageSize int32, bookMark string) (*ListOrganization, error) {
+	if err := validateInput(&pageInput{PageSize: pageSize, BookMark: bookMark}); err != nil {
+		return nil, err
+	}
+	var filter OrgFilter
+	if filterJSON != "" {
+		decoder := json.NewDecoder(strings.NewReader(filterJSON))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&filter); err != nil {
+			return nil, newValidationError("Invalid filter - %s", err)
+		}
+	}
+	switch filter.Status {
+	case "", OrgStatusActive, OrgStatusInactive, OrgStatusArchived:
+	default:
+		return nil, newValidationError("status should be either of %s, %s or %s", OrgStatusActive, OrgStatusInactive, OrgStatusArchived)
+	}
+	switch filter.PubKey {
+	case "", OrgKeyPresent, OrgKeyAbsent:
+	default:
+		return nil, newValidationError("pubKey should be either of %s or %s", OrgKeyPresent, OrgKeyAbsent)
+	}
+	// archived orgs are left out of the created index
+	objectType, keys := orgCreatedIndex, []string{}
+	switch {
+	case filter.InstitutionID != "":
+		objectType, keys = orgInstitutionIndex, []string{filter.InstitutionID}
+	case filter.ParentOrgID != "":
+		objectType, keys = orgParentIndex, []string{filter.ParentOrgID}
+	case filter.Status == OrgStatusArchived:
+		objectType = "Organization"
+	}
+	orgs := make([]*Organization, 0, pageSize)
+	for {
+		page, nextBookMark, err := s.filterOrgPage(ctx.GetStub(), objectType, keys, pageSize, bookMark, &filter, int(pageSize)-len(orgs))
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, page...)
+		bookMark = nextBookMark
+		if bookMark == "" || len(orgs) == int(pageSize) {
+			break
+		}
+	}
+	return &ListOrganization{
+		BookMark: bookMark,
+		Records:  orgs,
+	}, nil
+}
+
+// filterOrgPage reads pageSize keys of objectType from bookMark on and returns
+// at most limit orgs matching filter with the bookmark to continue from. When
+// the limit is reached the bookmark is the first key that was not looked at
+func (s *SmartContract) filterOrgPage(stub shim.ChaincodeStubInterface, objectType string, keys []string, pageSize int32, bookMark string, filter *OrgFilter, limit int) ([]*Organization, string, error) {
+	resultsIterator, meta, err := stub.GetStateByPartialCompositeKeyWithPagination(objectType, keys, pageSize, bookMark)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resultsIterator.Close()
+
+	orgs := make([]*Organization, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, "", err
+		}
+		if len(orgs) == limit {
+			return orgs, queryResult.Key, nil
+		}
+		org := &Organization{}
+		if objectType == "Organization" {
+			if err = json.Unmarshal(queryResult.Value, org); err != nil {
+				return nil, "", err
+			}
+		} else {
+			_, attributes, err := stub.SplitCompositeKey(queryResult.Key)
+			if err != nil {
+				return nil, "", err
+			}
+			if org, err = s.readOrg(stub, attributes[len(attributes)-1]); err != nil {
+				return nil, "", err
+			}
+		}
+		if filter.matches(org) {
+			orgs = append(orgs, org)
+		}
+	}
+	return orgs, meta.Bookmark, nil
+}
+
+func (filter *OrgFilter) matches(org *Organization) bool {
+	switch filter.Status {
+	case "":
+		if org.ArchivedAt != 0 {
+			return false
+		}
+	case OrgStatusActive, OrgStatusInactive:
+		if org.ArchivedAt != 0 || org.IsActive != (filter.Status == OrgStatusActive) {
+			return false
+		}
+	case OrgStatusArchived:
+		if org.ArchivedAt == 0 {
+			return false
+		}
+	}
+	if filter.InstitutionID != "" && org.InstitutionID != filter.InstitutionID {
+		return false
+	}
+	if filter.ParentOrgID != "" && org.ParentOrgID != filter.ParentOrgID {
+		return false
+	}
+	if filter.NamePrefix != "" && !strings.HasPrefix(org.Name, filter.NamePrefix) {
+		return false
+	}
+	if filter.PubKey != "" && (org.PubKeyPem != "") != (filter.PubKey == OrgKeyPresent) {
+		return false
+	}
+	if filter.CreatedFrom > 0 && org.CreateTxTimestamp < filter.CreatedFrom {
+		return false
+	}
+	if filter.CreatedTo > 0 && org.CreateTxTimestamp > filter.CreatedTo {
+		return false
+	}
+	return true
+}
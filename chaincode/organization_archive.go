@@ -0,0 +1,219 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/shopspring/decimal"
+)
+
+func assertOrgNotArchived(org *Organization) error {
+	if org.ArchivedAt > 0 {
+		return newConflictError("Org %s is archived", org.ID)
+	}
+	return nil
+}
+
+// ArchiveOrg deactivates an org and hides it from ListOrgs. The org, its
+// credit and logs are kept and stay readable
+func (s *SmartContract) ArchiveOrg(ctx contractapi.TransactionContextInterface, orgId string) error {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return err
+	}
+	org, err := s.readOrg(ctx.GetStub(), orgId)
+	if err != nil {
+		return err
+	}
+	if err = assertOrgNotArchived(org); err != nil {
+		return err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	createdId, err := s.newOrgCreatedIndexId(ctx.GetStub(), org.CreateTxTimestamp, org.ID)
+	if err != nil {
+		return err
+	}
+	if err = ctx.GetStub().DelState(createdId); err != nil {
+		return err
+	}
+	org.IsActive = false
+	org.ArchivedAt = ts.AsTime().UTC().Unix()
+	return s.updateOrg(ctx, org, org.InstitutionID, events.OrgArchived)
+}
+
+// PurgeOrg deletes an org created by mistake together with its credit, indexes,
+// signing policy and expired delegations. Orgs with a key, credit activity,
+// sub-orgs, members, active delegations, accreditations, trusts given or
+// received or open signing requests are kept
+func (s *SmartContract) PurgeOrg(ctx contractapi.TransactionContextInterface, orgId string) error {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return err
+	}
+	stub := ctx.GetStub()
+	org, err := s.readOrg(stub, orgId)
+	if err != nil {
+		return err
+	}
+	if org.PubKeyPem != "" {
+		return newConflictError("Org %s has a public key, remove it first", orgId)
+	}
+	for _, objectType := range []string{orgParentIndex, "OrgMembership", "Accreditation", "OrgTrust~trusted", "OrgTrust~truster", "SigningRequest~org"} {
+		hasState, err := hasStateByPartialCompositeKey(stub, objectType, []string{orgId})
+		if err != nil {
+			return err
+		}
+		if hasState {
+			return newConflictError("Org %s has sub-orgs, members, accreditations, trusts given or received or open signing requests, remove them first", orgId)
+		}
+	}
+	if err = s.purgeOrgRoleDelegations(stub, org.ID); err != nil {
+		return err
+	}
+	if err = s.purgeOrgCredit(stub, org); err != nil {
+		return err
+	}
+	policyId, err := s.newOrgSigningPolicyStateId(stub, org.ID)
+	if err != nil {
+		return err
+	}
+	if err = stub.DelState(policyId); err != nil {
+		return err
+	}
+	createdId, err := s.newOrgCreatedIndexId(stub, org.CreateTxTimestamp, org.ID)
+	if err != nil {
+		return err
+	}
+	if err = stub.DelState(createdId); err != nil {
+		return err
+	}
+	if err = s.moveOrgInstitutionIndex(stub, org.ID, org.InstitutionID, ""); err != nil {
+		return err
+	}
+	if err = s.delOrgParentIndex(stub, org.ID, org.ParentOrgID); err != nil {
+		return err
+	}
+	stateId, err := s.newOrgStateId(stub, org.ID)
+	if err != nil {
+		return err
+	}
+	if err = stub.DelState(stateId); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, events.OrgPurged, newOrgEvent(org))
+}
+
+// purgeOrgRoleDelegations deletes the expired delegations of an org, failing
+// when one is still active
+func (s *SmartContract) purgeOrgRoleDelegations(stub shim.ChaincodeStubInterface, orgId string) error {
+	delegations, err := s.indexedOrgRoleDelegations(stub, []string{orgId})
+	if err != nil {
+		return err
+	}
+	active, err := activeOrgRoleDelegations(stub, delegations)
+	if err != nil {
+		return err
+	}
+	if len(active) > 0 {
+		return newConflictError("Org %s has active delegations, revoke them first", orgId)
+	}
+	for _, delegation := range delegations {
+		indexId, err := s.newOrgRoleDelegationGranteeIndexId(stub, delegation.OrgID, delegation.GranteeMSPID, delegation.GranteeIdentityHash, delegation.ID)
+		if err != nil {
+			return err
+		}
+		stateId, err := s.newOrgRoleDelegationStateId(stub, delegation.ID)
+		if err != nil {
+			return err
+		}
+		for _, id := range []string{indexId, stateId} {
+			if err = stub.DelState(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// purgeOrgCredit deletes the credit of an org and its creation log, failing
+// when the credit has a balance or any other log
+func (s *SmartContract) purgeOrgCredit(stub shim.ChaincodeStubInterface, org *Organization) error {
+	creditStateId, err := s.newOrgCreditStateId(stub, org.OrgCreditID, org.ID)
+	if err != nil {
+		return err
+	}
+	creditJSON, err := stub.GetState(creditStateId)
+	if err != nil {
+		return err
+	}
+	if creditJSON == nil {
+		return nil
+	}
+	var credit OrgCredit
+	if err = json.Unmarshal(creditJSON, &credit); err != nil {
+		return err
+	}
+	amount, err := decimal.NewFromString(credit.Amount)
+	if err != nil {
+		return err
+	}
+	if !amount.IsZero() {
+		return newConflictError("Org %s has credit activity", org.ID)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(orgCreditLogIndex, []string{credit.OrgID, credit.ID})
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+	logIndexIds := make([]string, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		logIndexIds = append(logIndexIds, queryResult.Key)
+	}
+	if len(logIndexIds) > 1 {
+		return newConflictError("Org %s has credit activity", org.ID)
+	}
+	for _, logIndexId := range logIndexIds {
+		_, attributes, err := stub.SplitCompositeKey(logIndexId)
+		if err != nil {
+			return err
+		}
+		logStateId, err := s.newOrgCreditLogStateId(stub, attributes[len(attributes)-1])
+		if err != nil {
+			return err
+		}
+		logJSON, err := stub.GetState(logStateId)
+		if err != nil {
+			return err
+		}
+		var orgCreditLog OrgCreditLog
+		if err = json.Unmarshal(logJSON, &orgCreditLog); err != nil {
+			return err
+		}
+		descId, err := s.newOrgCreditLogDescIndexId(stub, orgCreditLog.OrgID, orgCreditLog.CreditID, orgCreditLog.TxTimestamp, orgCreditLog.ID)
+		if err != nil {
+			return err
+		}
+		for _, id := range []string{logIndexId, descId, logStateId} {
+			if err = stub.DelState(id); err != nil {
+				return err
+			}
+		}
+	}
+	return stub.DelState(creditStateId)
+}
+
+func hasStateByPartialCompositeKey(stub shim.ChaincodeStubInterface, objectType string, keys []string) (bool, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return false, err
+	}
+	defer resultsIterator.Close()
+	return resultsIterator.HasNext(), nil
+}
@@ -0,0 +1,54 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeOrgKeepsTrustedOrg(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG1")
+	createOrg(t, l, su, "ORG2")
+	trust, err := sc.TrustOrg(l.as(su), "ORG1", "ORG2", "diploma", l.now.Unix(), l.now.Unix()+3600)
+	require.NoError(t, err)
+
+	requireErrorCode(t, chaincode.ErrorCodeConflict, sc.PurgeOrg(l.as(su), "ORG2"))
+	requireErrorCode(t, chaincode.ErrorCodeConflict, sc.PurgeOrg(l.as(su), "ORG1"))
+
+	require.NoError(t, sc.RevokeOrgTrust(l.as(su), "ORG1", trust.ID))
+	require.NoError(t, sc.PurgeOrg(l.as(su), "ORG2"))
+	require.NoError(t, sc.PurgeOrg(l.as(su), "ORG1"))
+}
+
+func TestPurgeOrgDeletesSigningPolicy(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG1")
+	_, err := sc.SetOrgSigningPolicy(l.as(su), "ORG1", chaincode.OrgSigningPolicyInput{SignedKeys: []string{"hash"}, MinSigners: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, sc.PurgeOrg(l.as(su), "ORG1"))
+	_, err = sc.ReadOrgSigningPolicy(l.as(su), "ORG1")
+	requireErrorCode(t, chaincode.ErrorCodeNotFound, err)
+}
+
+func TestPurgeOrgKeepsSigningRequestOrg(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createKeyedOrg(t, l, su, "ORG1")
+	createKeyedOrg(t, l, su, "ORG2")
+	request, err := sc.OpenSigningRequest(l.as(su), "ORG1", documentHash, []string{"ORG2"})
+	require.NoError(t, err)
+	require.NoError(t, sc.RemoveOrgPublicKey(l.as(su), "ORG2"))
+
+	requireErrorCode(t, chaincode.ErrorCodeConflict, sc.PurgeOrg(l.as(su), "ORG2"))
+
+	require.NoError(t, sc.CancelSigningRequest(l.as(su), "ORG1", request.ID))
+	require.NoError(t, sc.PurgeOrg(l.as(su), "ORG2"))
+}
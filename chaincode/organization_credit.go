@@ -2,8 +2,9 @@ package chaincode
 
 import (
 	"encoding/json"
-	"fmt"
 
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/query"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/shopspring/decimal"
@@ -14,6 +15,7 @@ type OrgCredit struct {
 	ID          string `json:"id"`
 	OrgID       string `json:"orgId"`
 	Amount      string `json:"amount"`
+	UpdatedBy   Actor  `json:"updatedBy" metadata:",optional"`
 	TxTimestamp int64  `json:"txTimestamp"`
 }
 
@@ -28,6 +30,8 @@ type OrgCreditLog struct {
 	Amount      string `json:"amount"`
 	Credit      string `json:"credit"`
 	Debit       string `json:"debit"`
+	ActorID     string `json:"actorId" metadata:",optional"`
+	Actor       Actor  `json:"actor" metadata:",optional"`
 	TxTimestamp int64  `json:"txTimestamp"`
 }
 
@@ -37,22 +41,19 @@ type ListOrgCreditLog struct {
 }
 
 func (s *SmartContract) CreditExists(ctx contractapi.TransactionContextInterface, id string, orgId string) (bool, error) {
-	if err := s.IsIdentitySuperAdmin(ctx); err != nil {
-		return false, err
-	}
 	stateId, err := s.newOrgCreditStateId(ctx.GetStub(), id, orgId)
 	if err != nil {
 		return false, err
 	}
 	state, err := ctx.GetStub().GetState(stateId)
 	if err != nil {
-		return false, fmt.Errorf("Failed to read world state - %s", err)
+		return false, newInternalError("Failed to read world state - %s", err)
 	}
 	return state != nil, nil
 }
 
 func (s *SmartContract) CreateCredit(ctx contractapi.TransactionContextInterface, orgId string, title string, amount string) (*OrgCredit, error) {
-	if err := s.IsIdentitySuperAdmin(ctx); err != nil {
+	if err := validateInput(&createCreditInput{OrgID: orgId, Title: title, Amount: amount}); err != nil {
 		return nil, err
 	}
 	creditId := orgId
@@ -61,7 +62,20 @@ func (s *SmartContract) CreateCredit(ctx contractapi.TransactionContextInterface
 		return nil, err
 	}
 	if exists {
-		return nil, fmt.Errorf("Credit %s already exists", creditId)
+		return nil, newAlreadyExistsError("Credit %s already exists", creditId)
+	}
+	initialAmount, err := decimal.NewFromString(amount)
+	if err != nil {
+		return nil, err
+	}
+	if !initialAmount.IsZero() {
+		requiresApproval, err := s.requiresApproval(ctx.GetStub(), OperationCreditMint)
+		if err != nil {
+			return nil, err
+		}
+		if requiresApproval {
+			return nil, newPermissionDeniedError("Initial credit requires approval, create with 0 and use ProposeMint")
+		}
 	}
 	ts, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
@@ -71,24 +85,40 @@ func (s *SmartContract) CreateCredit(ctx contractapi.TransactionContextInterface
 	if err != nil {
 		return nil, err
 	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
 	orgCredit := OrgCredit{
 		DocType:     "OrgCredit",
 		ID:          creditId,
 		OrgID:       orgId,
 		Amount:      amount,
+		UpdatedBy:   actor,
 		TxTimestamp: ts.AsTime().Unix(),
 	}
 	orgCreditJSON, err := json.Marshal(orgCredit)
 	if err != nil {
 		return nil, err
 	}
-	err = ctx.GetStub().PutState(stateId, orgCreditJSON)
-	err = createCreditLog(s, ctx.GetStub(), orgCredit, "Create Credit", "mint", "0", amount)
-	return &orgCredit, err
+	if err = ctx.GetStub().PutState(stateId, orgCreditJSON); err != nil {
+		return nil, err
+	}
+	orgCreditLog, err := createCreditLog(s, ctx.GetStub(), orgCredit, "Create Credit", "mint", "0", amount)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.CreditCreated, newCreditEvent(orgCreditLog)); err != nil {
+		return nil, err
+	}
+	return &orgCredit, nil
 }
 
 func (s *SmartContract) MintCredit(ctx contractapi.TransactionContextInterface, creditId string, orgId string, amount string, title string) error {
-	if err := s.IsIdentitySuperAdmin(ctx); err != nil {
+	if err := validateInput(&CreditChangePayload{CreditID: creditId, OrgID: orgId, Amount: amount, Title: title}); err != nil {
+		return err
+	}
+	if err := s.assertDirectOperationAllowed(ctx.GetStub(), OperationCreditMint); err != nil {
 		return err
 	}
 	ts, err := ctx.GetStub().GetTxTimestamp()
@@ -103,28 +133,31 @@ func (s *SmartContract) MintCredit(ctx contractapi.TransactionContextInterface,
 }
 
 func (s *SmartContract) BurnCredit(ctx contractapi.TransactionContextInterface, creditId string, orgId string, amount string, title string) error {
-	if err := s.IsIdentitySuperAdmin(ctx); err != nil {
+	if err := validateInput(&CreditChangePayload{CreditID: creditId, OrgID: orgId, Amount: amount, Title: title}); err != nil {
+		return err
+	}
+	if err := s.assertDirectOperationAllowed(ctx.GetStub(), OperationCreditBurn); err != nil {
 		return err
 	}
 	ts, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
 		return err
 	}
-	if err = s.burnOrgCredit(ctx.GetStub(), creditId, orgId, amount, title, ts.AsTime().Unix(), "burn"); err != nil {
+	if err = s.burnOrgCredit(ctx, creditId, orgId, amount, title, ts.AsTime().Unix(), "burn"); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (s *SmartContract) SpendCredit(ctx contractapi.TransactionContextInterface, creditId string, orgId string, amount string, title string) error {
-	if err := s.IsIdentityAdminOfOrg(ctx, orgId); err != nil {
+	if err := validateInput(&CreditChangePayload{CreditID: creditId, OrgID: orgId, Amount: amount, Title: title}); err != nil {
 		return err
 	}
 	ts, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
 		return err
 	}
-	if err = s.burnOrgCredit(ctx.GetStub(), creditId, orgId, amount, title, ts.AsTime().Unix(), "spend"); err != nil {
+	if err = s.burnOrgCredit(ctx, creditId, orgId, amount, title, ts.AsTime().Unix(), "spend"); err != nil {
 		return err
 	}
 	return nil
@@ -140,17 +173,20 @@ func (s *SmartContract) mintOrgCredit(ctx contractapi.TransactionContextInterfac
 	if err != nil {
 		return err
 	}
+	if orgCreditJSON == nil {
+		return newNotFoundError("Credit %s does not exist", creditId)
+	}
 	var orgCredit OrgCredit
 	if err = json.Unmarshal(orgCreditJSON, &orgCredit); err != nil {
 		return err
 	}
 	creditAmount, err := decimal.NewFromString(amount)
-	if creditAmount.LessThan(decimal.Zero) {
-		return fmt.Errorf("Credit is lower than 0")
-	}
 	if err != nil {
 		return err
 	}
+	if creditAmount.LessThan(decimal.Zero) {
+		return newInsufficientFundsError("Credit is lower than 0")
+	}
 	oldCreditAmount, err := decimal.NewFromString(orgCredit.Amount)
 	if err != nil {
 		return err
@@ -158,8 +194,13 @@ func (s *SmartContract) mintOrgCredit(ctx contractapi.TransactionContextInterfac
 
 	newAmount := creditAmount.Add(oldCreditAmount)
 
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return err
+	}
 	orgCredit.Amount = newAmount.String()
 	orgCredit.TxTimestamp = ts
+	orgCredit.UpdatedBy = actor
 
 	newOrgCreditJSON, err := json.Marshal(orgCredit)
 	if err != nil {
@@ -169,14 +210,16 @@ func (s *SmartContract) mintOrgCredit(ctx contractapi.TransactionContextInterfac
 	if err = ctx.GetStub().PutState(creditStateId, newOrgCreditJSON); err != nil {
 		return err
 	}
-	if err = createCreditLog(s, ctx.GetStub(), orgCredit, title, logType, "0", amount); err != nil {
+	orgCreditLog, err := createCreditLog(s, ctx.GetStub(), orgCredit, title, logType, "0", amount)
+	if err != nil {
 		return err
 	}
-	return nil
+	return s.emitEvent(ctx, creditEventType(logType), newCreditEvent(orgCreditLog))
 }
 
 // burns credit and create log without checking any permission
-func (s *SmartContract) burnOrgCredit(stub shim.ChaincodeStubInterface, creditId string, orgId string, amount string, title string, ts int64, logType string) error {
+func (s *SmartContract) burnOrgCredit(ctx contractapi.TransactionContextInterface, creditId string, orgId string, amount string, title string, ts int64, logType string) error {
+	stub := ctx.GetStub()
 	creditStateId, err := s.newOrgCreditStateId(stub, creditId, orgId)
 	if err != nil {
 		return err
@@ -185,6 +228,9 @@ func (s *SmartContract) burnOrgCredit(stub shim.ChaincodeStubInterface, creditId
 	if err != nil {
 		return err
 	}
+	if orgCreditJSON == nil {
+		return newNotFoundError("Credit %s does not exist", creditId)
+	}
 	var orgCredit OrgCredit
 	err = json.Unmarshal(orgCreditJSON, &orgCredit)
 	if err != nil {
@@ -197,18 +243,26 @@ func (s *SmartContract) burnOrgCredit(stub shim.ChaincodeStubInterface, creditId
 	}
 
 	subtractAmount, err := decimal.NewFromString(amount)
+	if err != nil {
+		return err
+	}
 	if subtractAmount.LessThanOrEqual(decimal.Zero) {
 		// return fmt.Errorf("Credit is lower than or equals to 0")
 		return nil
 	}
 	if subtractAmount.GreaterThan(oldCreditAmount) {
-		return fmt.Errorf("Amount exceeds remaining credit")
+		return newInsufficientFundsError("Amount exceeds remaining credit")
 	}
 
 	newAmount := oldCreditAmount.Sub(subtractAmount)
 
+	actor, err := s.newActor(stub)
+	if err != nil {
+		return err
+	}
 	orgCredit.Amount = newAmount.String()
 	orgCredit.TxTimestamp = ts
+	orgCredit.UpdatedBy = actor
 
 	newOrgCreditJSON, err := json.Marshal(orgCredit)
 	if err != nil {
@@ -217,28 +271,33 @@ func (s *SmartContract) burnOrgCredit(stub shim.ChaincodeStubInterface, creditId
 	if err = stub.PutState(creditStateId, newOrgCreditJSON); err != nil {
 		return err
 	}
-	if err = createCreditLog(s, stub, orgCredit, title, logType, amount, "0"); err != nil {
+	orgCreditLog, err := createCreditLog(s, stub, orgCredit, title, logType, amount, "0")
+	if err != nil {
 		return err
 	}
-	return nil
+	return s.emitEvent(ctx, creditEventType(logType), newCreditEvent(orgCreditLog))
 }
 
-func createCreditLog(s *SmartContract, stub shim.ChaincodeStubInterface, orgCredit OrgCredit, title string, logType string, credit string, debit string) error {
+func createCreditLog(s *SmartContract, stub shim.ChaincodeStubInterface, orgCredit OrgCredit, title string, logType string, credit string, debit string) (*OrgCreditLog, error) {
 	id := stub.GetTxID()
 	orgCreditLogStateId, err := s.newOrgCreditLogStateId(stub, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	existing, err := stub.GetState(orgCreditLogStateId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if existing != nil {
-		return fmt.Errorf("Credit %s already exists", id)
+		return nil, newAlreadyExistsError("Credit %s already exists", id)
 	}
 	ts, err := stub.GetTxTimestamp()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	actor, err := s.newActor(stub)
+	if err != nil {
+		return nil, err
 	}
 	orgCreditLog := OrgCreditLog{
 		DocType:     "OrgCreditLog",
@@ -251,16 +310,44 @@ func createCreditLog(s *SmartContract, stub shim.ChaincodeStubInterface, orgCred
 		Amount:      orgCredit.Amount,
 		Credit:      credit,
 		Debit:       debit,
+		ActorID:     actor.ID,
+		Actor:       actor,
 		TxTimestamp: ts.AsTime().Unix(),
 	}
 	orgCreditLogJSON, err := json.Marshal(orgCreditLog)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err = stub.PutState(orgCreditLogStateId, orgCreditLogJSON); err != nil {
-		return err
+		return nil, err
+	}
+	if err = s.putOrgCreditLogIndexes(stub, &orgCreditLog); err != nil {
+		return nil, err
+	}
+	return &orgCreditLog, nil
+}
+
+// creditEventType maps a credit log type to its event type
+func creditEventType(logType string) string {
+	switch logType {
+	case "burn":
+		return events.CreditBurned
+	case "spend":
+		return events.CreditSpent
+	}
+	return events.CreditMinted
+}
+
+func newCreditEvent(orgCreditLog *OrgCreditLog) events.Credit {
+	return events.Credit{
+		ID:      orgCreditLog.CreditID,
+		OrgID:   orgCreditLog.OrgID,
+		LogID:   orgCreditLog.ID,
+		Title:   orgCreditLog.Title,
+		Credit:  orgCreditLog.Credit,
+		Debit:   orgCreditLog.Debit,
+		Balance: orgCreditLog.Amount,
 	}
-	return nil
 }
 
 func (s *SmartContract) ListCreditLog(ctx contractapi.TransactionContextInterface, orgId string, creditId string) ([]*OrgCreditLog, error) {
@@ -268,20 +355,7 @@ func (s *SmartContract) ListCreditLog(ctx contractapi.TransactionContextInterfac
 	if err != nil {
 		return nil, err
 	}
-	queryString := fmt.Sprintf(`
-	{
-		"selector": {
-			"docType":"%s",
-			"creditId": "%s",
-			"orgId": "%s"
-		},
-		"sort": [
-			{
-				"txTimestamp": "%s"
-			}
-		]
-	}`, "OrgCreditLog", credit.ID, credit.OrgID, "desc")
-	parsed, _, err := getQueryResultForQueryStringFromCreditLog(ctx, queryString, 100, "")
+	parsed, _, err := s.listCreditLogFromIndex(ctx.GetStub(), credit, query.Desc, 100, "")
 	if err != nil {
 		return nil, err
 	}
@@ -289,27 +363,14 @@ func (s *SmartContract) ListCreditLog(ctx contractapi.TransactionContextInterfac
 }
 
 func (s *SmartContract) ListCreditLogPaginated(ctx contractapi.TransactionContextInterface, orgId string, creditId string, sortArg string, pageSize int32, bookMark string) (*ListOrgCreditLog, error) {
+	if err := validateInput(&sortedPageInput{SortArg: sortArg, PageSize: pageSize, BookMark: bookMark}); err != nil {
+		return nil, err
+	}
 	credit, err := s.ReadCredit(ctx, creditId, orgId)
 	if err != nil {
 		return nil, err
 	}
-	if sortArg != "asc" && sortArg != "desc" {
-		return nil, fmt.Errorf("sortArg should be either of asc org desc")
-	}
-	queryString := fmt.Sprintf(`
-	{
-		"selector": {
-			"docType":"%s",
-			"creditId": "%s",
-			"orgId": "%s"
-		},
-		"sort": [
-			{
-				"txTimestamp": "%s"
-			}
-		]
-	}`, "OrgCreditLog", credit.ID, credit.OrgID, sortArg)
-	parsed, bookMark, err := getQueryResultForQueryStringFromCreditLog(ctx, queryString, pageSize, bookMark)
+	parsed, bookMark, err := s.listCreditLogFromIndex(ctx.GetStub(), credit, sortArg, pageSize, bookMark)
 	if err != nil {
 		return nil, err
 	}
@@ -320,49 +381,59 @@ func (s *SmartContract) ListCreditLogPaginated(ctx contractapi.TransactionContex
 }
 
 func (s *SmartContract) ReadCredit(ctx contractapi.TransactionContextInterface, creditId string, orgId string) (*OrgCredit, error) {
-	if err := s.IsIdentitySuperAdminOrAdminOfOrg(ctx, orgId); err != nil {
-		return nil, err
-	}
 	creditStateId, err := s.newOrgCreditStateId(ctx.GetStub(), creditId, orgId)
 	if err != nil {
 		return nil, err
 	}
 	creditJSON, err := ctx.GetStub().GetState(creditStateId)
+	if err != nil {
+		return nil, err
+	}
+	if creditJSON == nil {
+		return nil, newNotFoundError("Credit %s does not exist", creditId)
+	}
 	var credit OrgCredit
 	if err = json.Unmarshal(creditJSON, &credit); err != nil {
 		return nil, err
 	}
-	return &credit, err
+	return &credit, nil
 }
 
-func getQueryResultForQueryStringFromCreditLog(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookMark string) ([]*OrgCreditLog, string, error) {
-	resultsIterator, meta, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookMark)
-	if err != nil {
-		return nil, "", err
+// listCreditLogFromIndex pages through the credit log index in timestamp order
+func (s *SmartContract) listCreditLogFromIndex(stub shim.ChaincodeStubInterface, credit *OrgCredit, sortArg string, pageSize int32, bookMark string) ([]*OrgCreditLog, string, error) {
+	index := orgCreditLogIndex
+	if sortArg == query.Desc {
+		index = orgCreditLogDescIndex
 	}
-	defer resultsIterator.Close()
-
-	parsed, err := constructQueryResponseFromIteratorFromCreditLog(resultsIterator)
+	resultsIterator, meta, err := stub.GetStateByPartialCompositeKeyWithPagination(index, []string{credit.OrgID, credit.ID}, pageSize, bookMark)
 	if err != nil {
 		return nil, "", err
 	}
-	return parsed, meta.Bookmark, err
-}
+	defer resultsIterator.Close()
 
-func constructQueryResponseFromIteratorFromCreditLog(resultsIterator shim.StateQueryIteratorInterface) ([]*OrgCreditLog, error) {
 	var data []*OrgCreditLog = make([]*OrgCreditLog, 0)
 	for resultsIterator.HasNext() {
 		queryResult, err := resultsIterator.Next()
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
-		var item OrgCreditLog
-		err = json.Unmarshal(queryResult.Value, &item)
+		_, attributes, err := stub.SplitCompositeKey(queryResult.Key)
 		if err != nil {
-			return nil, err
+			return nil, "", err
+		}
+		logStateId, err := s.newOrgCreditLogStateId(stub, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, "", err
+		}
+		logJSON, err := stub.GetState(logStateId)
+		if err != nil {
+			return nil, "", err
+		}
+		var item OrgCreditLog
+		if err = json.Unmarshal(logJSON, &item); err != nil {
+			return nil, "", err
 		}
 		data = append(data, &item)
 	}
-
-	return data, nil
+	return data, meta.Bookmark, nil
 }
@@ -0,0 +1,221 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxOrgDepth is the number of levels an org tree can have, an institution
+// with schools and their faculties is 3 levels deep
+const maxOrgDepth = 8
+
+// CreateSubOrg creates an org under parentOrgId. Admins of the parent can
+// create sub-orgs, their credit starts at 0
+func (s *SmartContract) CreateSubOrg(ctx contractapi.TransactionContextInterface, parentOrgId string, input CreateOrgInput) (*Organization, error) {
+	if err := validateInput(&referenceInput{ID: parentOrgId}); err != nil {
+		return nil, err
+	}
+	if input.ParentOrgID != "" && input.ParentOrgID != parentOrgId {
+		return nil, newValidationError("parentOrgId should be %s", parentOrgId)
+	}
+	if input.InitialCredit != "" && input.InitialCredit != "0" {
+		return nil, newValidationError("initialCredit is not allowed for sub-orgs, use ProposeMint")
+	}
+	input.ParentOrgID = parentOrgId
+	input.InitialCredit = ""
+	return s.createOrg(ctx, &input)
+}
+
+// ArchiveSubOrg archives orgId when it is a descendant of parentOrgId
+func (s *SmartContract) ArchiveSubOrg(ctx contractapi.TransactionContextInterface, parentOrgId string, orgId string) error {
+	if err := validateInput(&referenceInput{ID: parentOrgId}); err != nil {
+		return err
+	}
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return err
+	}
+	ancestorIds, err := s.orgAncestorIds(ctx.GetStub(), orgId)
+	if err != nil {
+		return err
+	}
+	if !containsString(ancestorIds, parentOrgId) {
+		return newNotFoundError("Org %s is not a sub-org of %s", orgId, parentOrgId)
+	}
+	return s.ArchiveOrg(ctx, orgId)
+}
+
+// SetOrgParent moves an org with its sub-orgs under parentOrgId, an empty
+// parentOrgId makes it a top level org
+func (s *SmartContract) SetOrgParent(ctx contractapi.TransactionContextInterface, orgId string, parentOrgId string) (*Organization, error) {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return nil, err
+	}
+	org, err := s.readOrg(ctx.GetStub(), orgId)
+	if err != nil {
+		return nil, err
+	}
+	if err = assertOrgNotArchived(org); err != nil {
+		return nil, err
+	}
+	if org.ParentOrgID == parentOrgId {
+		return org, nil
+	}
+	if parentOrgId != "" {
+		if err = s.assertOrgParentAllowed(ctx.GetStub(), orgId, parentOrgId); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.delOrgParentIndex(ctx.GetStub(), org.ID, org.ParentOrgID); err != nil {
+		return nil, err
+	}
+	if err = s.putOrgParentIndex(ctx.GetStub(), org.ID, parentOrgId); err != nil {
+		return nil, err
+	}
+	org.ParentOrgID = parentOrgId
+	if err = s.updateOrg(ctx, org, org.InstitutionID, events.OrgUpdated); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ListSubOrgs lists the direct sub-orgs of an org that are not archived
+func (s *SmartContract) ListSubOrgs(ctx contractapi.TransactionContextInterface, orgId string) ([]*Organization, error) {
+	childIds, err := s.childOrgIds(ctx.GetStub(), orgId)
+	if err != nil {
+		return nil, err
+	}
+	return s.readActiveOrgs(ctx.GetStub(), childIds)
+}
+
+// ListOrgDescendants lists every sub-org below an org that is not archived,
+// level by level
+func (s *SmartContract) ListOrgDescendants(ctx contractapi.TransactionContextInterface, orgId string) ([]*Organization, error) {
+	descendantIds, _, err := s.descendantOrgIds(ctx.GetStub(), orgId)
+	if err != nil {
+		return nil, err
+	}
+	return s.readActiveOrgs(ctx.GetStub(), descendantIds)
+}
+
+func (s *SmartContract) readActiveOrgs(stub shim.ChaincodeStubInterface, orgIds []string) ([]*Organization, error) {
+	orgs := make([]*Organization, 0, len(orgIds))
+	for _, orgId := range orgIds {
+		org, err := s.readOrg(stub, orgId)
+		if err != nil {
+			return nil, err
+		}
+		if org.ArchivedAt == 0 {
+			orgs = append(orgs, org)
+		}
+	}
+	return orgs, nil
+}
+
+// assertOrgParentAllowed checks that parentOrgId can be the parent of orgId
+// without a cycle or a tree deeper than maxOrgDepth
+func (s *SmartContract) assertOrgParentAllowed(stub shim.ChaincodeStubInterface, orgId string, parentOrgId string) error {
+	if parentOrgId == orgId {
+		return newConflictError("Org %s can not be its own parent", orgId)
+	}
+	parent, err := s.readOrg(stub, parentOrgId)
+	if err != nil {
+		return err
+	}
+	if err = assertOrgNotArchived(parent); err != nil {
+		return err
+	}
+	ancestorIds, err := s.orgAncestorIds(stub, parentOrgId)
+	if err != nil {
+		return err
+	}
+	if containsString(ancestorIds, orgId) {
+		return newConflictError("Org %s is an ancestor of %s", orgId, parentOrgId)
+	}
+	_, height, err := s.descendantOrgIds(stub, orgId)
+	if err != nil {
+		return err
+	}
+	if len(ancestorIds)+2+height > maxOrgDepth {
+		return newValidationError("Org tree should not be deeper than %d levels", maxOrgDepth)
+	}
+	return nil
+}
+
+// orgAncestorIds returns the parent of an org, its parent and so on
+func (s *SmartContract) orgAncestorIds(stub shim.ChaincodeStubInterface, orgId string) ([]string, error) {
+	ancestorIds := make([]string, 0)
+	parentOrgId, err := s.orgParentId(stub, orgId)
+	for err == nil && parentOrgId != "" && len(ancestorIds) < maxOrgDepth {
+		ancestorIds = append(ancestorIds, parentOrgId)
+		parentOrgId, err = s.orgParentId(stub, parentOrgId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ancestorIds, nil
+}
+
+// orgParentId returns the parent of an org, empty for top level and unknown orgs
+func (s *SmartContract) orgParentId(stub shim.ChaincodeStubInterface, orgId string) (string, error) {
+	stateId, err := s.newOrgStateId(stub, orgId)
+	if err != nil {
+		return "", err
+	}
+	orgJSON, err := stub.GetState(stateId)
+	if err != nil || orgJSON == nil {
+		return "", err
+	}
+	var org Organization
+	if err = json.Unmarshal(orgJSON, &org); err != nil {
+		return "", err
+	}
+	return org.ParentOrgID, nil
+}
+
+func (s *SmartContract) childOrgIds(stub shim.ChaincodeStubInterface, orgId string) ([]string, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(orgParentIndex, []string{orgId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	childIds := make([]string, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+		childIds = append(childIds, attributes[len(attributes)-1])
+	}
+	return childIds, nil
+}
+
+// descendantOrgIds returns the sub-orgs below an org level by level and the
+// number of levels below it
+func (s *SmartContract) descendantOrgIds(stub shim.ChaincodeStubInterface, orgId string) ([]string, int, error) {
+	descendantIds := make([]string, 0)
+	level := []string{orgId}
+	height := 0
+	for len(level) > 0 && height < maxOrgDepth {
+		nextLevel := make([]string, 0)
+		for _, id := range level {
+			childIds, err := s.childOrgIds(stub, id)
+			if err != nil {
+				return nil, 0, err
+			}
+			nextLevel = append(nextLevel, childIds...)
+		}
+		if len(nextLevel) > 0 {
+			height++
+		}
+		descendantIds = append(descendantIds, nextLevel...)
+		level = nextLevel
+	}
+	return descendantIds, height, nil
+}
@@ -0,0 +1,374 @@
+package chaincode_test
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func orgIds(orgs []*chaincode.Organization) []string {
+	ids := make([]string, 0, len(orgs))
+	for _, org := range orgs {
+		ids = append(ids, org.ID)
+	}
+	return ids
+}
+
+func TestListOrgsBeforeIndexRebuild(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG2")
+	createOrg(t, l, su, "ORG1")
+	for key := range l.state {
+		if strings.HasPrefix(key, "\x00Organization~created\x00") {
+			delete(l.state, key)
+		}
+	}
+
+	orgs, err := sc.ListOrgs(l.as(su))
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG2", "ORG1"}, orgIds(orgs))
+
+	_, err = sc.RebuildIndexes(l.as(su))
+	require.NoError(t, err)
+	orgs, err = sc.ListOrgs(l.as(su))
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG2", "ORG1"}, orgIds(orgs))
+}
+
+func TestListOrgsPaginatedFilters(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	for _, input := range []chaincode.CreateOrgInput{
+		{OrgID: "ORG1", Name: "School One", InstitutionID: "INST1"},
+		{OrgID: "ORG2", Name: "School Two", InstitutionID: "INST2", Status: chaincode.OrgStatusInactive},
+		{OrgID: "ORG3", Name: "University", InstitutionID: "INST1"},
+	} {
+		_, err := sc.CreateOrgWithInput(l.as(su), input)
+		require.NoError(t, err)
+	}
+
+	page, err := sc.ListOrgsPaginated(l.as(su), "", 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG2"}, orgIds(page.Records))
+	require.NotEmpty(t, page.BookMark)
+	page, err = sc.ListOrgsPaginated(l.as(su), "", 2, page.BookMark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG3"}, orgIds(page.Records))
+	require.Empty(t, page.BookMark)
+
+	page, err = sc.ListOrgsPaginated(l.as(su), `{"status":"active","institutionId":"INST1"}`, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG3"}, orgIds(page.Records))
+	page, err = sc.ListOrgsPaginated(l.as(su), `{"namePrefix":"School"}`, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG2"}, orgIds(page.Records))
+	page, err = sc.ListOrgsPaginated(l.as(su), `{"status":"inactive"}`, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG2"}, orgIds(page.Records))
+
+	require.NoError(t, sc.ArchiveOrg(l.as(su), "ORG3"))
+	page, err = sc.ListOrgsPaginated(l.as(su), `{"institutionId":"INST1"}`, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1"}, orgIds(page.Records))
+	page, err = sc.ListOrgsPaginated(l.as(su), `{"status":"archived"}`, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG3"}, orgIds(page.Records))
+
+	_, err = sc.ListOrgsPaginated(l.as(su), `{"status":"closed"}`, 10, "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	_, err = sc.ListOrgsPaginated(l.as(su), `{"color":"red"}`, 10, "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+}
+
+func TestListOrgsPaginatedEachFilter(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	for _, input := range []chaincode.CreateOrgInput{
+		{OrgID: "ORG1", Name: "School One", InstitutionID: "INST1"},
+		{OrgID: "ORG2", Name: "School Two", InstitutionID: "INST2", ParentOrgID: "ORG1", Status: chaincode.OrgStatusInactive},
+		{OrgID: "ORG3", Name: "University", InstitutionID: "INST1", ParentOrgID: "ORG1"},
+		{OrgID: "ORG4", Name: "Academy"},
+	} {
+		_, err := sc.CreateOrgWithInput(l.as(su), input)
+		require.NoError(t, err)
+	}
+	setOrgKey(t, l, su, "ORG3")
+	require.NoError(t, sc.ArchiveOrg(l.as(su), "ORG4"))
+	all, err := sc.ListOrgsPaginated(l.as(su), "", 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG2", "ORG3"}, orgIds(all.Records))
+	created := all.Records[1].CreateTxTimestamp
+
+	for filter, want := range map[string][]string{
+		`{"status":"active"}`:                                              {"ORG1", "ORG3"},
+		`{"status":"inactive"}`:                                            {"ORG2"},
+		`{"status":"archived"}`:                                            {"ORG4"},
+		`{"institutionId":"INST1"}`:                                        {"ORG1", "ORG3"},
+		`{"institutionId":"INST3"}`:                                        {},
+		`{"parentOrgId":"ORG1"}`:                                           {"ORG2", "ORG3"},
+		`{"parentOrgId":"ORG1","status":"inactive"}`:                       {"ORG2"},
+		`{"namePrefix":"School"}`:                                          {"ORG1", "ORG2"},
+		`{"namePrefix":"Academy"}`:                                         {},
+		`{"pubKey":"present"}`:                                             {"ORG3"},
+		`{"pubKey":"absent"}`:                                              {"ORG1", "ORG2"},
+		fmt.Sprintf(`{"createdFrom":%d}`, created):                         {"ORG2", "ORG3"},
+		fmt.Sprintf(`{"createdTo":%d}`, created):                           {"ORG1", "ORG2"},
+		fmt.Sprintf(`{"createdFrom":%d,"createdTo":%d}`, created, created): {"ORG2"},
+	} {
+		page, err := sc.ListOrgsPaginated(l.as(su), filter, 10, "")
+		require.NoError(t, err, filter)
+		require.Equal(t, want, orgIds(page.Records), filter)
+		require.Empty(t, page.BookMark, filter)
+	}
+
+	_, err = sc.ListOrgsPaginated(l.as(su), `{"pubKey":"maybe"}`, 10, "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	_, err = sc.ListOrgsPaginated(l.as(su), "", 0, "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+}
+
+func TestListOrgsPaginatedFillsPages(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	for i := 1; i <= 7; i++ {
+		input := chaincode.CreateOrgInput{OrgID: fmt.Sprintf("ORG%d", i), Name: fmt.Sprintf("Org %d", i)}
+		if i%2 == 0 {
+			input.Status = chaincode.OrgStatusInactive
+		}
+		_, err := sc.CreateOrgWithInput(l.as(su), input)
+		require.NoError(t, err)
+	}
+
+	var pages [][]string
+	bookMark := ""
+	for {
+		page, err := sc.ListOrgsPaginated(l.as(su), `{"status":"active"}`, 2, bookMark)
+		require.NoError(t, err)
+		pages = append(pages, orgIds(page.Records))
+		if bookMark = page.BookMark; bookMark == "" {
+			break
+		}
+	}
+	require.Equal(t, [][]string{{"ORG1", "ORG3"}, {"ORG5", "ORG7"}}, pages)
+
+	page, err := sc.ListOrgsPaginated(l.as(su), `{"status":"inactive"}`, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG2", "ORG4"}, orgIds(page.Records))
+	require.NotEmpty(t, page.BookMark)
+	page, err = sc.ListOrgsPaginated(l.as(su), `{"status":"inactive"}`, 2, page.BookMark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG6"}, orgIds(page.Records))
+	require.Empty(t, page.BookMark)
+}
+
+func TestListOrgsPaginatedByInstitutionIndex(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	for i := 1; i <= 5; i++ {
+		input := chaincode.CreateOrgInput{OrgID: fmt.Sprintf("ORG%d", i), Name: fmt.Sprintf("Org %d", i), InstitutionID: "INST2"}
+		if i%2 == 1 {
+			input.InstitutionID = "INST1"
+		}
+		_, err := sc.CreateOrgWithInput(l.as(su), input)
+		require.NoError(t, err)
+	}
+
+	page, err := sc.ListOrgsPaginated(l.as(su), `{"institutionId":"INST1"}`, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG3"}, orgIds(page.Records))
+	require.NotEmpty(t, page.BookMark)
+	calls := l.stub.GetStateByPartialCompositeKeyWithPaginationCallCount()
+	objectType, keys, _, _ := l.stub.GetStateByPartialCompositeKeyWithPaginationArgsForCall(calls - 1)
+	require.Equal(t, "Organization~institution", objectType)
+	require.Equal(t, []string{"INST1"}, keys)
+
+	page, err = sc.ListOrgsPaginated(l.as(su), `{"institutionId":"INST1"}`, 2, page.BookMark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG5"}, orgIds(page.Records))
+	require.Empty(t, page.BookMark)
+	require.Equal(t, calls+1, l.stub.GetStateByPartialCompositeKeyWithPaginationCallCount())
+
+	// the institution index moves along with the org
+	_, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG2", InstitutionID: "INST1"})
+	require.NoError(t, err)
+	page, err = sc.ListOrgsPaginated(l.as(su), `{"institutionId":"INST1"}`, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG2", "ORG3", "ORG5"}, orgIds(page.Records))
+}
+
+func TestGetOrgsByInstitution(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	for _, input := range []chaincode.CreateOrgInput{
+		{OrgID: "ORG1", Name: "School One", InstitutionID: "INST1"},
+		{OrgID: "ORG2", Name: "School Two", InstitutionID: "INST2"},
+		{OrgID: "ORG3", Name: "University", InstitutionID: "INST1"},
+		{OrgID: "ORG4", Name: "Academy", InstitutionID: "INST1"},
+	} {
+		_, err := sc.CreateOrgWithInput(l.as(su), input)
+		require.NoError(t, err)
+	}
+
+	orgs, err := sc.GetOrgsByInstitution(l.as(su), "INST1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG3", "ORG4"}, orgIds(orgs))
+	orgs, err = sc.GetOrgsByInstitution(l.as(su), "INST3")
+	require.NoError(t, err)
+	require.NotNil(t, orgs)
+	require.Empty(t, orgs)
+	_, err = sc.GetOrgsByInstitution(l.as(su), "")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+
+	// archived orgs keep their institution, purged orgs are gone
+	require.NoError(t, sc.ArchiveOrg(l.as(su), "ORG3"))
+	require.NoError(t, sc.ArchiveOrg(l.as(su), "ORG4"))
+	require.NoError(t, sc.PurgeOrg(l.as(su), "ORG4"))
+	orgs, err = sc.GetOrgsByInstitution(l.as(su), "INST1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG3"}, orgIds(orgs))
+	require.NotZero(t, orgs[1].ArchivedAt)
+
+	_, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG1", InstitutionID: "INST2"})
+	require.NoError(t, err)
+	orgs, err = sc.GetOrgsByInstitution(l.as(su), "INST1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG3"}, orgIds(orgs))
+	orgs, err = sc.GetOrgsByInstitution(l.as(su), "INST2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG2"}, orgIds(orgs))
+}
+
+func TestGetOrgByKeyFingerprint(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG1")
+	createOrg(t, l, su, "ORG2")
+	key := setOrgKey(t, l, su, "ORG2")
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	sum := sha256.Sum256(der)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	org, err := sc.GetOrgByKeyFingerprint(l.as(su), fingerprint)
+	require.NoError(t, err)
+	require.Equal(t, "ORG2", org.ID)
+	org, err = sc.GetOrgByKeyFingerprint(l.as(su), strings.ToUpper(fingerprint))
+	require.NoError(t, err)
+	require.Equal(t, "ORG2", org.ID)
+
+	other := sha256.Sum256([]byte("other key"))
+	_, err = sc.GetOrgByKeyFingerprint(l.as(su), hex.EncodeToString(other[:]))
+	requireErrorCode(t, chaincode.ErrorCodeNotFound, err)
+	for _, invalid := range []string{"", "abc", fingerprint[:62], fingerprint + "00", strings.Repeat("zz", 32)} {
+		_, err = sc.GetOrgByKeyFingerprint(l.as(su), invalid)
+		requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	}
+
+	// the key is released with its removal
+	require.NoError(t, sc.RemoveOrgPublicKey(l.as(su), "ORG2"))
+	_, err = sc.GetOrgByKeyFingerprint(l.as(su), fingerprint)
+	requireErrorCode(t, chaincode.ErrorCodeNotFound, err)
+	pubKeyPem := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, sc.SetOrgPublicKey(l.as(su), "ORG1", "ecdsa:P-384", pubKeyPem))
+	org, err = sc.GetOrgByKeyFingerprint(l.as(su), fingerprint)
+	require.NoError(t, err)
+	require.Equal(t, "ORG1", org.ID)
+}
+
+func TestUpdateOrgWithInput(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	_, err := sc.CreateOrgWithInput(l.as(su), chaincode.CreateOrgInput{
+		OrgID:           "ORG1",
+		Name:            "School One",
+		Desc:            "A school",
+		Email:           "info@school.mn",
+		InstitutionID:   "INST1",
+		InstitutionName: "Institution One",
+		LogoUrl:         "https://school.mn/logo.png",
+	})
+	require.NoError(t, err)
+	original, err := sc.ReadOrg(l.as(su), "ORG1")
+	require.NoError(t, err)
+
+	// omitted and zero value fields are kept
+	org, err := sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG1", Name: "School 1"})
+	require.NoError(t, err)
+	require.Equal(t, "School 1", org.Name)
+	require.Equal(t, original.Desc, org.Desc)
+	require.Equal(t, original.Email, org.Email)
+	require.Equal(t, original.InstitutionID, org.InstitutionID)
+	require.Equal(t, original.InstitutionName, org.InstitutionName)
+	require.Equal(t, original.LogoUrl, org.LogoUrl)
+	require.True(t, org.IsActive)
+	org, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG1", Desc: "", Email: "", Status: ""})
+	require.NoError(t, err)
+	require.Equal(t, original.Desc, org.Desc)
+	require.Equal(t, original.Email, org.Email)
+	require.True(t, org.IsActive)
+	stored, err := sc.ReadOrg(l.as(su), "ORG1")
+	require.NoError(t, err)
+	require.Equal(t, org, stored)
+
+	// Clear removes only the named fields
+	org, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG1", Clear: []string{"desc", "logoUrl"}})
+	require.NoError(t, err)
+	require.Empty(t, org.Desc)
+	require.Empty(t, org.LogoUrl)
+	require.Equal(t, "School 1", org.Name)
+	require.Equal(t, original.Email, org.Email)
+	require.Equal(t, original.InstitutionName, org.InstitutionName)
+
+	for _, input := range []chaincode.UpdateOrgInput{
+		{OrgID: "ORG1", Clear: []string{"name"}},
+		{OrgID: "ORG1", Clear: []string{"color"}},
+		{OrgID: "ORG1", Email: "new@school.mn", Clear: []string{"email"}},
+		{OrgID: "ORG1", Status: "closed"},
+	} {
+		_, err = sc.UpdateOrgWithInput(l.as(su), input)
+		requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	}
+	stored, err = sc.ReadOrg(l.as(su), "ORG1")
+	require.NoError(t, err)
+	require.Equal(t, org, stored)
+
+	// the institution index follows InstitutionID
+	_, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG1", InstitutionID: "INST2"})
+	require.NoError(t, err)
+	orgs, err := sc.GetOrgsByInstitution(l.as(su), "INST1")
+	require.NoError(t, err)
+	require.Empty(t, orgs)
+	orgs, err = sc.GetOrgsByInstitution(l.as(su), "INST2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1"}, orgIds(orgs))
+	_, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG1", Clear: []string{"institutionId"}})
+	require.NoError(t, err)
+	orgs, err = sc.GetOrgsByInstitution(l.as(su), "INST2")
+	require.NoError(t, err)
+	require.Empty(t, orgs)
+
+	org, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG1", Status: chaincode.OrgStatusInactive})
+	require.NoError(t, err)
+	require.False(t, org.IsActive)
+	require.NoError(t, sc.ArchiveOrg(l.as(su), "ORG1"))
+	_, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG1", Name: "School"})
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+	_, err = sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG2", Name: "School"})
+	requireErrorCode(t, chaincode.ErrorCodeNotFound, err)
+}
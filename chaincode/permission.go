@@ -1,69 +1,195 @@
 package chaincode
 
 import (
-	"fmt"
-
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 func (s *SmartContract) IsDiplomMNClient(ctx contractapi.TransactionContextInterface) (bool, error) {
-	role, roleFound, err := cid.GetAttributeValue(ctx.GetStub(), "diplom.mn.role")
+	config, err := s.readPlatformConfig(ctx.GetStub())
+	if err != nil {
+		return false, err
+	}
+	role, roleFound, err := cid.GetAttributeValue(ctx.GetStub(), config.ClientRoleAttribute)
 	if err != nil {
 		return false, err
 	}
 	if !roleFound {
-		return false, fmt.Errorf("role attribute not found in identity - %s", err)
+		return false, newPermissionDeniedError("role attribute not found in identity")
 	}
 	mspId, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
 		return false, err
 	}
-	if mspId == "DsolutionsOrgMSP" && role == "diplom-mn-client" {
+	if containsString(config.ClientMSPIDs, mspId) && role == config.ClientRoleValue {
 		return true, nil
 	}
 	return false, nil
 }
 
+// isIdentityAccreditor checks whether the submitting identity is an
+// accreditation authority, its role attribute is the client role attribute
+func (s *SmartContract) isIdentityAccreditor(ctx contractapi.TransactionContextInterface) (bool, error) {
+	config, err := s.readPlatformConfig(ctx.GetStub())
+	if err != nil {
+		return false, err
+	}
+	if config.AccreditorRoleValue == "" {
+		return false, nil
+	}
+	role, roleFound, err := cid.GetAttributeValue(ctx.GetStub(), config.ClientRoleAttribute)
+	if err != nil || !roleFound {
+		return false, err
+	}
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, err
+	}
+	return containsString(config.AccreditorMSPIDs, mspId) && role == config.AccreditorRoleValue, nil
+}
+
 func (s *SmartContract) IsIdentitySuperAdmin(ctx contractapi.TransactionContextInterface) error {
-	err := cid.AssertAttributeValue(ctx.GetStub(), "diplom-mn.admin", "true")
+	config, err := s.readPlatformConfig(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	err = cid.AssertAttributeValue(ctx.GetStub(), config.AdminAttribute, config.AdminAttributeValue)
 	if err != nil {
 		return InsufficientPermissionError
 	}
 
 	mspId, err := cid.GetMSPID(ctx.GetStub())
 	if err != nil {
-		return fmt.Errorf("Error when retrieving mspId")
+		return newInternalError("Error when retrieving mspId")
 	}
-	if mspId != "DsolutionsOrgMSP" {
-		return fmt.Errorf("Insufficient Permission on MSPID")
+	if !containsString(config.AdminMSPIDs, mspId) {
+		return newPermissionDeniedError("Insufficient Permission on MSPID")
 	}
 	return nil
 }
 
-func (s *SmartContract) IdentityHasRoleOnOrg(ctx contractapi.TransactionContextInterface, orgId string, role string) error {
-	err := cid.AssertAttributeValue(ctx.GetStub(), "diplom.mn.org.id", orgId)
+// identityOrgRoles collects the roles of the submitting identity on an org
+// including roles delegated to it. An admin of a parent org is admin of the org
+func (s *SmartContract) identityOrgRoles(ctx contractapi.TransactionContextInterface, orgId string) ([]string, error) {
+	return s.identityInheritedOrgRoles(ctx, orgId, s.identityOwnOrgRoles)
+}
+
+// identityDelegableOrgRoles collects the roles the submitting identity may
+// delegate on an org, its direct roles and the admin role of a parent org it
+// holds directly. Delegated roles can not be delegated further
+func (s *SmartContract) identityDelegableOrgRoles(ctx contractapi.TransactionContextInterface, orgId string) ([]string, error) {
+	return s.identityInheritedOrgRoles(ctx, orgId, s.identityDirectOrgRoles)
+}
+
+// identityInheritedOrgRoles collects orgRoles of an org and adds the admin
+// role when orgRoles of one of its ancestors has it
+func (s *SmartContract) identityInheritedOrgRoles(ctx contractapi.TransactionContextInterface, orgId string, orgRoles func(contractapi.TransactionContextInterface, string) ([]string, error)) ([]string, error) {
+	roles, err := orgRoles(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.readPlatformConfig(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	if containsString(roles, config.OrgAdminRole) {
+		return roles, nil
+	}
+	ancestorIds, err := s.orgAncestorIds(ctx.GetStub(), orgId)
+	if err != nil {
+		return nil, err
+	}
+	for _, ancestorId := range ancestorIds {
+		ancestorRoles, err := orgRoles(ctx, ancestorId)
+		if err != nil {
+			return nil, err
+		}
+		if containsString(ancestorRoles, config.OrgAdminRole) {
+			return append(roles, config.OrgAdminRole), nil
+		}
+	}
+	return roles, nil
+}
+
+// identityOwnOrgRoles collects the roles of the submitting identity on an org
+// and the roles delegated to it on the org
+func (s *SmartContract) identityOwnOrgRoles(ctx contractapi.TransactionContextInterface, orgId string) ([]string, error) {
+	roles, err := s.identityDirectOrgRoles(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	mspId, clientId, err := getClientIdentity(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	delegations, err := s.identityOrgRoleDelegations(ctx.GetStub(), orgId, mspId, identityHash(clientId))
+	if err != nil {
+		return nil, err
+	}
+	for _, delegation := range delegations {
+		roles = append(roles, delegation.Role)
+	}
+	return roles, nil
+}
+
+// identityDirectOrgRoles collects the roles of the submitting identity on an org
+// from the certificate attributes and the membership registry
+func (s *SmartContract) identityDirectOrgRoles(ctx contractapi.TransactionContextInterface, orgId string) ([]string, error) {
+	config, err := s.readPlatformConfig(ctx.GetStub())
 	if err != nil {
-		return fmt.Errorf("Insufficient Permission - orgId mismatch")
+		return nil, err
 	}
-	err = cid.AssertAttributeValue(ctx.GetStub(), "diplom.mn.org.role", role)
+	roles := []string{}
+	if cid.AssertAttributeValue(ctx.GetStub(), config.OrgIDAttribute, orgId) == nil {
+		role, roleFound, err := cid.GetAttributeValue(ctx.GetStub(), config.OrgRoleAttribute)
+		if err != nil {
+			return nil, err
+		}
+		if !roleFound || role == "" {
+			role = RoleOrgMember
+		}
+		roles = append(roles, role)
+	}
+	membership, err := s.readMyOrgMembership(ctx.GetStub(), orgId)
 	if err != nil {
-		return fmt.Errorf("Insufficient Role Permission")
+		return nil, err
+	}
+	if membership != nil {
+		roles = append(roles, membership.Roles...)
+	}
+	return roles, nil
+}
+
+// IdentityHasRoleOnOrg checks the certificate attributes and the membership registry
+func (s *SmartContract) IdentityHasRoleOnOrg(ctx contractapi.TransactionContextInterface, orgId string, role string) error {
+	roles, err := s.identityOrgRoles(ctx, orgId)
+	if err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return newPermissionDeniedError("Insufficient Permission - orgId mismatch")
+	}
+	if !containsString(roles, role) {
+		return newPermissionDeniedError("Insufficient Role Permission")
 	}
 	return nil
 }
 
 func (s *SmartContract) IdentityHasOrgID(ctx contractapi.TransactionContextInterface, orgId string) error {
-	err := cid.AssertAttributeValue(ctx.GetStub(), "diplom.mn.org.id", orgId)
+	roles, err := s.identityOrgRoles(ctx, orgId)
 	if err != nil {
-		return fmt.Errorf("Insufficient Permission - orgId mismatch")
+		return err
+	}
+	if len(roles) == 0 {
+		return newPermissionDeniedError("Insufficient Permission - orgId mismatch")
 	}
 	return nil
 }
 
 func (s *SmartContract) IsIdentitySuperAdminOrAdminOfOrg(ctx contractapi.TransactionContextInterface, orgId string) error {
 	if s.IsIdentitySuperAdmin(ctx) != nil {
-		if err := s.IdentityHasRoleOnOrg(ctx, orgId, "admin"); err != nil {
+		if err := s.IsIdentityAdminOfOrg(ctx, orgId); err != nil {
 			return err
 		}
 	}
@@ -71,7 +197,11 @@ func (s *SmartContract) IsIdentitySuperAdminOrAdminOfOrg(ctx contractapi.Transac
 }
 
 func (s *SmartContract) IsIdentityAdminOfOrg(ctx contractapi.TransactionContextInterface, orgId string) error {
-	if err := s.IdentityHasRoleOnOrg(ctx, orgId, "admin"); err != nil {
+	config, err := s.readPlatformConfig(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	if err := s.IdentityHasRoleOnOrg(ctx, orgId, config.OrgAdminRole); err != nil {
 		return err
 	}
 	return nil
@@ -85,3 +215,56 @@ func (s *SmartContract) IsIdentitySuperAdminOrHasAnyRoleOnOrg(ctx contractapi.Tr
 	}
 	return nil
 }
+
+// getClientIdentity returns MSP ID and certificate ID of the submitting identity
+func getClientIdentity(stub shim.ChaincodeStubInterface) (string, string, error) {
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return "", "", newInternalError("Error when retrieving mspId")
+	}
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return "", "", newInternalError("Error when retrieving client id")
+	}
+	return mspId, id, nil
+}
+
+// Actor identifies who performed a change
+type Actor struct {
+	MSPID   string `json:"mspId"`
+	ID      string `json:"id"`
+	OrgID   string `json:"orgId"`
+	OrgRole string `json:"orgRole"`
+	IsAdmin bool   `json:"isAdmin"`
+}
+
+// newActor captures the submitting identity and its relevant attributes
+func (s *SmartContract) newActor(stub shim.ChaincodeStubInterface) (Actor, error) {
+	mspId, clientId, err := getClientIdentity(stub)
+	if err != nil {
+		return Actor{}, err
+	}
+	config, err := s.readPlatformConfig(stub)
+	if err != nil {
+		return Actor{}, err
+	}
+	orgId, _, err := cid.GetAttributeValue(stub, config.OrgIDAttribute)
+	if err != nil {
+		return Actor{}, err
+	}
+	orgRole, _, err := cid.GetAttributeValue(stub, config.OrgRoleAttribute)
+	if err != nil {
+		return Actor{}, err
+	}
+	admin, _, err := cid.GetAttributeValue(stub, config.AdminAttribute)
+	if err != nil {
+		return Actor{}, err
+	}
+	return Actor{
+		MSPID:   mspId,
+		ID:      clientId,
+		OrgID:   orgId,
+		OrgRole: orgRole,
+		IsAdmin: containsString(config.AdminMSPIDs, mspId) && admin == config.AdminAttributeValue,
+	}, nil
+}
@@ -0,0 +1,136 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PlatformConfig holds the identity settings the permission helpers rely on
+type PlatformConfig struct {
+	DocType             string   `json:"docType" metadata:",optional"`
+	AdminMSPIDs         []string `json:"adminMspIds"`
+	AdminAttribute      string   `json:"adminAttribute"`
+	AdminAttributeValue string   `json:"adminAttributeValue"`
+	ClientMSPIDs        []string `json:"clientMspIds"`
+	ClientRoleAttribute string   `json:"clientRoleAttribute"`
+	ClientRoleValue     string   `json:"clientRoleValue"`
+	OrgIDAttribute      string   `json:"orgIdAttribute"`
+	OrgRoleAttribute    string   `json:"orgRoleAttribute"`
+	OrgAdminRole        string   `json:"orgAdminRole"`
+	AccreditorMSPIDs    []string `json:"accreditorMspIds" metadata:",optional"`
+	AccreditorRoleValue string   `json:"accreditorRoleValue" metadata:",optional"`
+	UpdatedBy           Actor    `json:"updatedBy" metadata:",optional"`
+	TxTimestamp         int64    `json:"txTimestamp" metadata:",optional"`
+}
+
+func defaultPlatformConfig() *PlatformConfig {
+	return &PlatformConfig{
+		DocType:             "PlatformConfig",
+		AdminMSPIDs:         []string{"DsolutionsOrgMSP"},
+		AdminAttribute:      "diplom-mn.admin",
+		AdminAttributeValue: "true",
+		ClientMSPIDs:        []string{"DsolutionsOrgMSP"},
+		ClientRoleAttribute: "diplom.mn.role",
+		ClientRoleValue:     "diplom-mn-client",
+		OrgIDAttribute:      "diplom.mn.org.id",
+		OrgRoleAttribute:    "diplom.mn.org.role",
+		OrgAdminRole:        "admin",
+		AccreditorMSPIDs:    []string{"DsolutionsOrgMSP"},
+		AccreditorRoleValue: "accreditation-authority",
+	}
+}
+
+func (s *SmartContract) ReadPlatformConfig(ctx contractapi.TransactionContextInterface) (*PlatformConfig, error) {
+	return s.readPlatformConfig(ctx.GetStub())
+}
+
+// UpdatePlatformConfig replaces the platform configuration. It is a governed
+// operation, see OperationPlatformConfig
+func (s *SmartContract) UpdatePlatformConfig(ctx contractapi.TransactionContextInterface, config PlatformConfig) error {
+	if err := s.assertDirectOperationAllowed(ctx.GetStub(), OperationPlatformConfig); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return s.executeOperation(ctx, OperationPlatformConfig, string(payload))
+}
+
+func (s *SmartContract) readPlatformConfig(stub shim.ChaincodeStubInterface) (*PlatformConfig, error) {
+	stateId, err := s.newPlatformConfigStateId(stub)
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	if configJSON == nil {
+		return defaultPlatformConfig(), nil
+	}
+	var config PlatformConfig
+	if err = json.Unmarshal(configJSON, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (s *SmartContract) putPlatformConfig(stub shim.ChaincodeStubInterface, config *PlatformConfig) error {
+	if err := validatePlatformConfig(config); err != nil {
+		return err
+	}
+	stateId, err := s.newPlatformConfigStateId(stub)
+	if err != nil {
+		return err
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	actor, err := s.newActor(stub)
+	if err != nil {
+		return err
+	}
+	config.DocType = "PlatformConfig"
+	config.UpdatedBy = actor
+	config.TxTimestamp = ts.AsTime().Unix()
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(stateId, configJSON)
+}
+
+func validatePlatformConfig(config *PlatformConfig) error {
+	if len(config.AdminMSPIDs) == 0 {
+		return newValidationError("adminMspIds should not be empty")
+	}
+	if config.AdminAttribute == "" || config.AdminAttributeValue == "" {
+		return newValidationError("adminAttribute and adminAttributeValue are required")
+	}
+	if config.ClientRoleAttribute == "" || config.ClientRoleValue == "" {
+		return newValidationError("clientRoleAttribute and clientRoleValue are required")
+	}
+	if config.OrgIDAttribute == "" || config.OrgRoleAttribute == "" || config.OrgAdminRole == "" {
+		return newValidationError("orgIdAttribute, orgRoleAttribute and orgAdminRole are required")
+	}
+	if config.ClientMSPIDs == nil {
+		config.ClientMSPIDs = []string{}
+	}
+	if config.AccreditorMSPIDs == nil {
+		config.AccreditorMSPIDs = []string{}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+const stagingConfigJSON = `{
+	"adminMspIds":["StagingMSP"],"adminAttribute":"staging.admin","adminAttributeValue":"true",
+	"clientMspIds":["StagingMSP"],"clientRoleAttribute":"staging.role","clientRoleValue":"client",
+	"orgIdAttribute":"staging.org.id","orgRoleAttribute":"staging.org.role","orgAdminRole":"admin"}`
+
+func TestInitLedgerWithConfig(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	stagingAdmin := newIdentity(t, "StagingMSP", "staging-admin", map[string]string{"staging.admin": "true"})
+
+	require.NoError(t, sc.InitLedger(l.as(stagingAdmin), stagingConfigJSON))
+	// the config is seeded once
+	require.NoError(t, sc.InitLedger(l.as(stagingAdmin), ""))
+	config, err := sc.ReadPlatformConfig(l.as(stagingAdmin))
+	require.NoError(t, err)
+	require.Equal(t, []string{"StagingMSP"}, config.AdminMSPIDs)
+
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, l.call(newSuperAdmin(t, "su"), "UpdatePlatformConfig"))
+	require.NoError(t, l.call(stagingAdmin, "UpdatePlatformConfig"))
+	config.ClientMSPIDs = []string{"StagingMSP", "PartnerMSP"}
+	require.NoError(t, sc.UpdatePlatformConfig(l.ctx, *config))
+
+	config, err = sc.ReadPlatformConfig(l.as(stagingAdmin))
+	require.NoError(t, err)
+	require.Equal(t, []string{"StagingMSP", "PartnerMSP"}, config.ClientMSPIDs)
+}
+
+func TestInitLedgerDefaults(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+
+	requireErrorCode(t, chaincode.ErrorCodeValidation, sc.InitLedger(l.as(su), "{bad"))
+	requireErrorCode(t, chaincode.ErrorCodeValidation, sc.InitLedger(l.as(su), `{"adminMspIds":[]}`))
+	require.NoError(t, sc.InitLedger(l.as(su), ""))
+	config, err := sc.ReadPlatformConfig(l.as(su))
+	require.NoError(t, err)
+	require.Equal(t, []string{"DsolutionsOrgMSP"}, config.AdminMSPIDs)
+	require.NoError(t, l.call(su, "UpdatePlatformConfig"))
+}
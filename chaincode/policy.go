@@ -0,0 +1,312 @@
+package chaincode
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	PermissionPublic               = "public"
+	PermissionOrgCreate            = "org.create"
+	PermissionOrgUpdate            = "org.update"
+	PermissionOrgUpdateSelf        = "org.update.self"
+	PermissionOrgKeyManage         = "org.key.manage"
+	PermissionOrgArchive           = "org.archive"
+	PermissionOrgPurge             = "org.purge"
+	PermissionOrgSubManage         = "org.suborgs.manage"
+	PermissionOrgApplicationReview = "org.application.review"
+	PermissionAccreditationManage  = "accreditation.manage"
+	PermissionOrgMembersRead       = "org.members.read"
+	PermissionOrgMembersManage     = "org.members.manage"
+	PermissionOrgRolesDelegate     = "org.roles.delegate"
+	PermissionOrgTrustManage       = "org.trust.manage"
+	PermissionOrgSigningManage     = "org.signing.manage"
+	PermissionOrgSigningRequest    = "org.signing.request"
+	PermissionOrgSign              = "org.sign"
+	PermissionDocumentAnchor       = "document.anchor"
+	PermissionCreditCreate         = "credit.create"
+	PermissionCreditRead           = "credit.read"
+	PermissionCreditMint           = "credit.mint"
+	PermissionCreditBurn           = "credit.burn"
+	PermissionCreditSpend          = "credit.spend"
+	PermissionGovernance           = "governance.manage"
+	PermissionPlatformConfig       = "platform.config"
+	PermissionIdentityDeny         = "identity.deny"
+	PermissionIndexRebuild         = "index.rebuild"
+)
+
+const (
+	RoleSuperAdmin = "superadmin"
+	RoleAccreditor = "accreditor"
+	RoleOrgAdmin   = "admin"
+	RoleOrgMember  = "member"
+)
+
+// rolePermissions bundles permissions per role. Org roles other than the admin
+// role get the member bundle
+var rolePermissions = map[string][]string{
+	RoleSuperAdmin: {
+		PermissionOrgCreate,
+		PermissionOrgUpdate,
+		PermissionOrgUpdateSelf,
+		PermissionOrgKeyManage,
+		PermissionOrgArchive,
+		PermissionOrgPurge,
+		PermissionOrgSubManage,
+		PermissionOrgApplicationReview,
+		PermissionAccreditationManage,
+		PermissionOrgMembersRead,
+		PermissionOrgMembersManage,
+		PermissionOrgRolesDelegate,
+		PermissionOrgTrustManage,
+		PermissionOrgSigningManage,
+		PermissionOrgSigningRequest,
+		PermissionOrgSign,
+		PermissionDocumentAnchor,
+		PermissionCreditCreate,
+		PermissionCreditRead,
+		PermissionCreditMint,
+		PermissionCreditBurn,
+		PermissionCreditSpend,
+		PermissionGovernance,
+		PermissionPlatformConfig,
+		PermissionIdentityDeny,
+		PermissionIndexRebuild,
+	},
+	RoleAccreditor: {
+		PermissionAccreditationManage,
+	},
+	RoleOrgAdmin: {
+		PermissionOrgUpdateSelf,
+		PermissionOrgSubManage,
+		PermissionOrgMembersRead,
+		PermissionOrgMembersManage,
+		PermissionOrgRolesDelegate,
+		PermissionOrgTrustManage,
+		PermissionOrgSigningManage,
+		PermissionOrgSigningRequest,
+		PermissionOrgSign,
+		PermissionDocumentAnchor,
+		PermissionCreditRead,
+		PermissionCreditSpend,
+	},
+	RoleOrgMember: {
+		PermissionOrgMembersRead,
+	},
+}
+
+// TransactionPolicy is the permission required to call a transaction function.
+// OrgArg is the index of the org id parameter for org scoped permissions, -1 otherwise
+type TransactionPolicy struct {
+	Function   string `json:"function"`
+	Permission string `json:"permission"`
+	OrgArg     int    `json:"orgArg"`
+}
+
+var transactionPolicies = map[string]TransactionPolicy{
+	"InitLedger":                            {Permission: PermissionPublic, OrgArg: -1},
+	"GetMyIdentity":                         {Permission: PermissionPublic, OrgArg: -1},
+	"IsDiplomMNClient":                      {Permission: PermissionPublic, OrgArg: -1},
+	"IsIdentitySuperAdmin":                  {Permission: PermissionPublic, OrgArg: -1},
+	"IdentityHasRoleOnOrg":                  {Permission: PermissionPublic, OrgArg: -1},
+	"IdentityHasOrgID":                      {Permission: PermissionPublic, OrgArg: -1},
+	"IsIdentitySuperAdminOrAdminOfOrg":      {Permission: PermissionPublic, OrgArg: -1},
+	"IsIdentityAdminOfOrg":                  {Permission: PermissionPublic, OrgArg: -1},
+	"IsIdentitySuperAdminOrHasAnyRoleOnOrg": {Permission: PermissionPublic, OrgArg: -1},
+	"ListTransactionPolicies":               {Permission: PermissionPublic, OrgArg: -1},
+
+	"OrgExists":              {Permission: PermissionPublic, OrgArg: -1},
+	"ReadOrg":                {Permission: PermissionPublic, OrgArg: -1},
+	"ReadMyOrg":              {Permission: PermissionPublic, OrgArg: -1},
+	"ListOrgs":               {Permission: PermissionPublic, OrgArg: -1},
+	"ListOrgsPaginated":      {Permission: PermissionPublic, OrgArg: -1},
+	"GetOrgsByInstitution":   {Permission: PermissionPublic, OrgArg: -1},
+	"GetOrgByKeyFingerprint": {Permission: PermissionPublic, OrgArg: -1},
+	"CreateOrg":              {Permission: PermissionOrgCreate, OrgArg: -1},
+	"UpdateOrg":              {Permission: PermissionOrgUpdate, OrgArg: -1},
+	"CreateOrgWithInput":     {Permission: PermissionOrgCreate, OrgArg: -1},
+	"UpdateOrgWithInput":     {Permission: PermissionOrgUpdate, OrgArg: -1},
+	"UpdateMyOrg":            {Permission: PermissionOrgUpdateSelf, OrgArg: 0},
+	"SetOrgPublicKey":        {Permission: PermissionOrgKeyManage, OrgArg: -1},
+	"RemoveOrgPublicKey":     {Permission: PermissionOrgKeyManage, OrgArg: -1},
+	"ArchiveOrg":             {Permission: PermissionOrgArchive, OrgArg: -1},
+	"PurgeOrg":               {Permission: PermissionOrgPurge, OrgArg: -1},
+	"CreateSubOrg":           {Permission: PermissionOrgSubManage, OrgArg: 0},
+	"ArchiveSubOrg":          {Permission: PermissionOrgSubManage, OrgArg: 0},
+	"SetOrgParent":           {Permission: PermissionOrgUpdate, OrgArg: -1},
+	"ListSubOrgs":            {Permission: PermissionPublic, OrgArg: -1},
+	"ListOrgDescendants":     {Permission: PermissionPublic, OrgArg: -1},
+
+	"SubmitOrgApplication":  {Permission: PermissionPublic, OrgArg: -1},
+	"ReadOrgApplication":    {Permission: PermissionPublic, OrgArg: -1},
+	"ApproveOrgApplication": {Permission: PermissionOrgApplicationReview, OrgArg: -1},
+	"RejectOrgApplication":  {Permission: PermissionOrgApplicationReview, OrgArg: -1},
+	"ListOrgApplications":   {Permission: PermissionOrgApplicationReview, OrgArg: -1},
+
+	"AddAccreditation":      {Permission: PermissionAccreditationManage, OrgArg: -1},
+	"RevokeAccreditation":   {Permission: PermissionAccreditationManage, OrgArg: -1},
+	"ListOrgAccreditations": {Permission: PermissionPublic, OrgArg: -1},
+	"IsOrgAccreditedFor":    {Permission: PermissionPublic, OrgArg: -1},
+
+	"GrantOrgRole":   {Permission: PermissionOrgMembersManage, OrgArg: 0},
+	"RevokeOrgRole":  {Permission: PermissionOrgMembersManage, OrgArg: 0},
+	"ListOrgMembers": {Permission: PermissionOrgMembersRead, OrgArg: 0},
+
+	"DelegateOrgRole":         {Permission: PermissionOrgRolesDelegate, OrgArg: 0},
+	"RevokeOrgRoleDelegation": {Permission: PermissionOrgRolesDelegate, OrgArg: 0},
+	"ListOrgRoleDelegations":  {Permission: PermissionOrgMembersRead, OrgArg: 0},
+
+	"TrustOrg":          {Permission: PermissionOrgTrustManage, OrgArg: 0},
+	"RevokeOrgTrust":    {Permission: PermissionOrgTrustManage, OrgArg: 0},
+	"ListOrgTrusts":     {Permission: PermissionPublic, OrgArg: -1},
+	"IsTrustedCoSigner": {Permission: PermissionPublic, OrgArg: -1},
+
+	"SetOrgSigningPolicy":   {Permission: PermissionOrgSigningManage, OrgArg: 0},
+	"ReadOrgSigningPolicy":  {Permission: PermissionPublic, OrgArg: -1},
+	"ValidateSigningBundle": {Permission: PermissionPublic, OrgArg: -1},
+
+	"OpenSigningRequest":    {Permission: PermissionOrgSigningRequest, OrgArg: 0},
+	"CancelSigningRequest":  {Permission: PermissionOrgSigningRequest, OrgArg: 0},
+	"SubmitSignature":       {Permission: PermissionOrgSign, OrgArg: 0},
+	"ReadSigningRequest":    {Permission: PermissionPublic, OrgArg: -1},
+	"ReadSignatureBundle":   {Permission: PermissionPublic, OrgArg: -1},
+	"ListPendingSignatures": {Permission: PermissionPublic, OrgArg: -1},
+
+	"AnchorDocumentHash": {Permission: PermissionDocumentAnchor, OrgArg: 0},
+	"LookupDocumentHash": {Permission: PermissionPublic, OrgArg: -1},
+	"ReadDocumentAnchor": {Permission: PermissionPublic, OrgArg: -1},
+
+	"CreditExists":           {Permission: PermissionCreditRead, OrgArg: 1},
+	"ReadCredit":             {Permission: PermissionCreditRead, OrgArg: 1},
+	"ListCreditLog":          {Permission: PermissionCreditRead, OrgArg: 0},
+	"ListCreditLogPaginated": {Permission: PermissionCreditRead, OrgArg: 0},
+	"CreateCredit":           {Permission: PermissionCreditCreate, OrgArg: -1},
+	"MintCredit":             {Permission: PermissionCreditMint, OrgArg: -1},
+	"BurnCredit":             {Permission: PermissionCreditBurn, OrgArg: -1},
+	"SpendCredit":            {Permission: PermissionCreditSpend, OrgArg: 1},
+
+	"ReadGovernancePolicy":   {Permission: PermissionPublic, OrgArg: -1},
+	"ListGovernancePolicies": {Permission: PermissionPublic, OrgArg: -1},
+	"SetGovernancePolicy":    {Permission: PermissionGovernance, OrgArg: -1},
+	"ProposeOperation":       {Permission: PermissionGovernance, OrgArg: -1},
+	"ProposeMint":            {Permission: PermissionGovernance, OrgArg: -1},
+	"ProposeBurn":            {Permission: PermissionGovernance, OrgArg: -1},
+	"ApproveProposal":        {Permission: PermissionGovernance, OrgArg: -1},
+	"RejectProposal":         {Permission: PermissionGovernance, OrgArg: -1},
+	"ExpireProposal":         {Permission: PermissionGovernance, OrgArg: -1},
+	"ReadProposal":           {Permission: PermissionGovernance, OrgArg: -1},
+	"ListProposals":          {Permission: PermissionGovernance, OrgArg: -1},
+
+	"ReadPlatformConfig":   {Permission: PermissionPublic, OrgArg: -1},
+	"UpdatePlatformConfig": {Permission: PermissionPlatformConfig, OrgArg: -1},
+
+	"DenyIdentity":         {Permission: PermissionIdentityDeny, OrgArg: -1},
+	"AllowIdentity":        {Permission: PermissionIdentityDeny, OrgArg: -1},
+	"ListDeniedIdentities": {Permission: PermissionIdentityDeny, OrgArg: -1},
+
+	"RebuildIndexes": {Permission: PermissionIndexRebuild, OrgArg: -1},
+}
+
+func (s *SmartContract) GetBeforeTransaction() interface{} {
+	return s.beforeTransaction
+}
+
+// beforeTransaction rejects denied identities and enforces the transaction
+// policy of the called function
+func (s *SmartContract) beforeTransaction(ctx contractapi.TransactionContextInterface) error {
+	if err := s.assertIdentityNotDenied(ctx.GetStub()); err != nil {
+		return err
+	}
+	fn, params := ctx.GetStub().GetFunctionAndParameters()
+	return s.enforceTransactionPolicy(ctx, transactionFunctionName(fn), params)
+}
+
+func (s *SmartContract) ListTransactionPolicies(ctx contractapi.TransactionContextInterface) ([]*TransactionPolicy, error) {
+	policies := make([]*TransactionPolicy, 0, len(transactionPolicies))
+	for fn, policy := range transactionPolicies {
+		policies = append(policies, &TransactionPolicy{Function: fn, Permission: policy.Permission, OrgArg: policy.OrgArg})
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Function < policies[j].Function
+	})
+	return policies, nil
+}
+
+// transactionFunctionName strips the contract namespace and normalizes the name
+// the same way contractapi does when looking up the function
+func transactionFunctionName(fn string) string {
+	if i := strings.LastIndex(fn, ":"); i >= 0 {
+		fn = fn[i+1:]
+	}
+	fnRune := []rune(fn)
+	if len(fnRune) > 0 && unicode.IsLower(fnRune[0]) {
+		fnRune[0] = unicode.ToUpper(fnRune[0])
+	}
+	return string(fnRune)
+}
+
+func (s *SmartContract) enforceTransactionPolicy(ctx contractapi.TransactionContextInterface, fn string, params []string) error {
+	policy, ok := transactionPolicies[fn]
+	if !ok {
+		return newPermissionDeniedError("Insufficient Permission - no policy defined for %s", fn)
+	}
+	if policy.Permission == PermissionPublic {
+		return nil
+	}
+	orgId := ""
+	if policy.OrgArg >= 0 {
+		if policy.OrgArg >= len(params) {
+			return newValidationError("Missing org id argument for %s", fn)
+		}
+		orgId = params[policy.OrgArg]
+	}
+	if err := s.identityHasPermission(ctx, policy.Permission, orgId); err != nil {
+		if errors.Is(err, InsufficientPermissionError) {
+			return newPermissionDeniedError("Insufficient Permission - %s requires %s", fn, policy.Permission)
+		}
+		return err
+	}
+	return nil
+}
+
+// identityHasPermission checks whether the submitting identity has a role that
+// bundles the permission, on orgId when it is not empty
+func (s *SmartContract) identityHasPermission(ctx contractapi.TransactionContextInterface, permission string, orgId string) error {
+	if s.IsIdentitySuperAdmin(ctx) == nil && containsString(rolePermissions[RoleSuperAdmin], permission) {
+		return nil
+	}
+	if containsString(rolePermissions[RoleAccreditor], permission) {
+		isAccreditor, err := s.isIdentityAccreditor(ctx)
+		if err != nil {
+			return err
+		}
+		if isAccreditor {
+			return nil
+		}
+	}
+	if orgId == "" {
+		return InsufficientPermissionError
+	}
+	roles, err := s.identityOrgRoles(ctx, orgId)
+	if err != nil {
+		return err
+	}
+	config, err := s.readPlatformConfig(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		bundle := RoleOrgMember
+		if role == config.OrgAdminRole {
+			bundle = RoleOrgAdmin
+		}
+		if containsString(rolePermissions[bundle], permission) {
+			return nil
+		}
+	}
+	return InsufficientPermissionError
+}
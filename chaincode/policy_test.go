@@ -0,0 +1,60 @@
+package chaincode_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEveryTransactionHasPolicy(t *testing.T) {
+	l := newLedger(t)
+	policies, err := (&chaincode.SmartContract{}).ListTransactionPolicies(l.as(newIdentity(t, "Org1MSP", "user", nil)))
+	require.NoError(t, err)
+	policyFunctions := map[string]bool{}
+	for _, policy := range policies {
+		policyFunctions[policy.Function] = true
+	}
+
+	contractMethods := map[string]bool{}
+	contractType := reflect.TypeOf(&contractapi.Contract{})
+	for i := 0; i < contractType.NumMethod(); i++ {
+		contractMethods[contractType.Method(i).Name] = true
+	}
+	smartContractType := reflect.TypeOf(&chaincode.SmartContract{})
+	for i := 0; i < smartContractType.NumMethod(); i++ {
+		name := smartContractType.Method(i).Name
+		if contractMethods[name] {
+			continue
+		}
+		require.True(t, policyFunctions[name], "no policy for %s", name)
+		delete(policyFunctions, name)
+	}
+	require.Empty(t, policyFunctions, "policies for functions that do not exist")
+}
+
+func TestBeforeTransactionEnforcesPolicy(t *testing.T) {
+	l := newLedger(t)
+	su := newSuperAdmin(t, "su")
+	user := newIdentity(t, "Org1MSP", "user", nil)
+	createOrg(t, l, su, "ORG1")
+	createOrg(t, l, su, "ORG2")
+
+	require.NoError(t, l.call(user, "ReadOrg", "ORG1"))
+	require.NoError(t, l.call(user, "organization:readOrg", "ORG1"))
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, l.call(user, "NoSuchFunction"))
+
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, l.call(user, "CreateOrg", "ORG3"))
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, l.call(newOrgAdmin(t, "ORG1"), "CreateOrg", "ORG3"))
+	require.NoError(t, l.call(su, "CreateOrg", "ORG3"))
+
+	require.NoError(t, l.call(newOrgAdmin(t, "ORG1"), "UpdateMyOrg", "ORG1"))
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, l.call(newOrgAdmin(t, "ORG2"), "UpdateMyOrg", "ORG1"))
+	requireErrorCode(t, chaincode.ErrorCodeValidation, l.call(newOrgAdmin(t, "ORG1"), "UpdateMyOrg"))
+
+	member := newIdentity(t, "Org1MSP", "member", map[string]string{"diplom.mn.org.id": "ORG1", "diplom.mn.org.role": "member"})
+	require.NoError(t, l.call(member, "ListOrgMembers", "ORG1"))
+	requireErrorCode(t, chaincode.ErrorCodePermissionDenied, l.call(member, "GrantOrgRole", "ORG1"))
+}
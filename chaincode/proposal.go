@@ -0,0 +1,371 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/query"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// enrollmentIdAttribute is the certificate attribute Fabric CA sets to the
+// enrollment id of the identity
+const enrollmentIdAttribute = "hf.EnrollmentID"
+
+const (
+	ProposalStatusPending  = "pending"
+	ProposalStatusExecuted = "executed"
+	ProposalStatusRejected = "rejected"
+	ProposalStatusExpired  = "expired"
+)
+
+type ProposalAction struct {
+	Action      string `json:"action"`
+	MSPID       string `json:"mspId"`
+	ClientID    string `json:"clientId"`
+	Reason      string `json:"reason"`
+	TxID        string `json:"txId"`
+	TxTimestamp int64  `json:"txTimestamp"`
+}
+
+type Proposal struct {
+	DocType           string            `json:"docType"`
+	ID                string            `json:"id"`
+	Operation         string            `json:"operation"`
+	Payload           string            `json:"payload"`
+	Status            string            `json:"status"`
+	Threshold         int               `json:"threshold"`
+	Approvers         []string          `json:"approvers"`
+	History           []*ProposalAction `json:"history"`
+	ExpiresAt         int64             `json:"expiresAt"`
+	CreateTxTimestamp int64             `json:"createTxTimestamp"`
+	UpdateTxTimestamp int64             `json:"updateTxTimestamp"`
+}
+
+// ProposeOperation opens a proposal for a governed operation. The payload is the
+// JSON encoded argument of the operation, e.g. OrgKeyPayload for org.key.set
+func (s *SmartContract) ProposeOperation(ctx contractapi.TransactionContextInterface, operation string, payload string) (*Proposal, error) {
+	if err := s.validateOperationPayload(operation, payload); err != nil {
+		return nil, err
+	}
+	policy, err := s.readGovernancePolicy(ctx.GetStub(), operation)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	id := ctx.GetStub().GetTxID()
+	stateId, err := s.newProposalStateId(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	proposal := &Proposal{
+		DocType:           "Proposal",
+		ID:                id,
+		Operation:         operation,
+		Payload:           payload,
+		Status:            ProposalStatusPending,
+		Threshold:         policy.Threshold,
+		Approvers:         []string{},
+		History:           []*ProposalAction{},
+		ExpiresAt:         ts.AsTime().Unix() + policy.TTLSeconds,
+		CreateTxTimestamp: ts.AsTime().Unix(),
+	}
+	if err = recordProposalAction(ctx.GetStub(), proposal, "propose", ""); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.ProposalCreated, newProposalEvent(proposal, "")); err != nil {
+		return nil, err
+	}
+	// proposer counts as the first approval
+	if err = s.approveProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	if err = putProposal(ctx.GetStub(), stateId, proposal); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func (s *SmartContract) ProposeMint(ctx contractapi.TransactionContextInterface, creditId string, orgId string, amount string, title string) (*Proposal, error) {
+	payload, err := json.Marshal(CreditChangePayload{CreditID: creditId, OrgID: orgId, Amount: amount, Title: title})
+	if err != nil {
+		return nil, err
+	}
+	return s.ProposeOperation(ctx, OperationCreditMint, string(payload))
+}
+
+func (s *SmartContract) ProposeBurn(ctx contractapi.TransactionContextInterface, creditId string, orgId string, amount string, title string) (*Proposal, error) {
+	payload, err := json.Marshal(CreditChangePayload{CreditID: creditId, OrgID: orgId, Amount: amount, Title: title})
+	if err != nil {
+		return nil, err
+	}
+	return s.ProposeOperation(ctx, OperationCreditBurn, string(payload))
+}
+
+func (s *SmartContract) ApproveProposal(ctx contractapi.TransactionContextInterface, id string) (*Proposal, error) {
+	if err := validateInput(&referenceInput{ID: id}); err != nil {
+		return nil, err
+	}
+	stateId, proposal, err := s.readPendingProposal(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.approveProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	if err = putProposal(ctx.GetStub(), stateId, proposal); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func (s *SmartContract) RejectProposal(ctx contractapi.TransactionContextInterface, id string, reason string) (*Proposal, error) {
+	if err := validateInput(&referenceInput{ID: id, Reason: reason}); err != nil {
+		return nil, err
+	}
+	stateId, proposal, err := s.readPendingProposal(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err = recordProposalAction(ctx.GetStub(), proposal, "reject", reason); err != nil {
+		return nil, err
+	}
+	proposal.Status = ProposalStatusRejected
+	if err = putProposal(ctx.GetStub(), stateId, proposal); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.ProposalRejected, newProposalEvent(proposal, reason)); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// ExpireProposal closes a pending proposal whose expiry has passed so that the
+// expiry is recorded in its history
+func (s *SmartContract) ExpireProposal(ctx contractapi.TransactionContextInterface, id string) (*Proposal, error) {
+	if err := validateInput(&referenceInput{ID: id}); err != nil {
+		return nil, err
+	}
+	stateId, err := s.newProposalStateId(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := s.readProposal(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != ProposalStatusPending {
+		return nil, newConflictError("Proposal %s is %s", id, proposal.Status)
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if ts.AsTime().Unix() <= proposal.ExpiresAt {
+		return nil, newConflictError("Proposal %s has not expired yet", id)
+	}
+	if err = recordProposalAction(ctx.GetStub(), proposal, "expire", ""); err != nil {
+		return nil, err
+	}
+	proposal.Status = ProposalStatusExpired
+	if err = putProposal(ctx.GetStub(), stateId, proposal); err != nil {
+		return nil, err
+	}
+	if err = s.emitEvent(ctx, events.ProposalExpired, newProposalEvent(proposal, "")); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+func (s *SmartContract) ReadProposal(ctx contractapi.TransactionContextInterface, id string) (*Proposal, error) {
+	if err := validateInput(&referenceInput{ID: id}); err != nil {
+		return nil, err
+	}
+	proposal, err := s.readProposal(ctx.GetStub(), id)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status == ProposalStatusPending && ts.AsTime().Unix() > proposal.ExpiresAt {
+		proposal.Status = ProposalStatusExpired
+	}
+	return proposal, nil
+}
+
+// ListProposals returns the proposals with status, newest first. An empty status
+// lists all of them
+func (s *SmartContract) ListProposals(ctx contractapi.TransactionContextInterface, status string) ([]*Proposal, error) {
+	if err := validateInput(&proposalStatusInput{Status: status}); err != nil {
+		return nil, err
+	}
+	q := query.New().Where("docType", "Proposal")
+	if status == "" {
+		q.UseIndex("proposal-index-2", "proposal-index-2")
+	} else {
+		q.Where("status", status).UseIndex("proposal-index-1", "proposal-index-1")
+	}
+	queryString, err := q.Sort("createTxTimestamp", query.Desc).String()
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var proposals []*Proposal = make([]*Proposal, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var proposal Proposal
+		if err = json.Unmarshal(queryResult.Value, &proposal); err != nil {
+			return nil, err
+		}
+		proposals = append(proposals, &proposal)
+	}
+	return proposals, nil
+}
+
+func (s *SmartContract) readProposal(stub shim.ChaincodeStubInterface, id string) (*Proposal, error) {
+	stateId, err := s.newProposalStateId(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	proposalJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	if proposalJSON == nil {
+		return nil, newNotFoundError("Proposal %s does not exist", id)
+	}
+	var proposal Proposal
+	if err = json.Unmarshal(proposalJSON, &proposal); err != nil {
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+func (s *SmartContract) readPendingProposal(stub shim.ChaincodeStubInterface, id string) (string, *Proposal, error) {
+	stateId, err := s.newProposalStateId(stub, id)
+	if err != nil {
+		return "", nil, err
+	}
+	proposal, err := s.readProposal(stub, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if proposal.Status != ProposalStatusPending {
+		return "", nil, newConflictError("Proposal %s is %s", id, proposal.Status)
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return "", nil, err
+	}
+	if ts.AsTime().Unix() > proposal.ExpiresAt {
+		return "", nil, newConflictError("Proposal %s has expired", id)
+	}
+	return stateId, proposal, nil
+}
+
+// approveProposal adds the submitter to the approvers and executes the
+// operation once the threshold is reached
+func (s *SmartContract) approveProposal(ctx contractapi.TransactionContextInterface, proposal *Proposal) error {
+	approver, err := proposalApprover(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	for _, existing := range proposal.Approvers {
+		if existing == approver {
+			return newConflictError("Proposal %s is already approved by this identity", proposal.ID)
+		}
+	}
+	proposal.Approvers = append(proposal.Approvers, approver)
+	if err = recordProposalAction(ctx.GetStub(), proposal, "approve", ""); err != nil {
+		return err
+	}
+	if err = s.emitEvent(ctx, events.ProposalApproved, newProposalEvent(proposal, "")); err != nil {
+		return err
+	}
+	if len(proposal.Approvers) < proposal.Threshold {
+		return nil
+	}
+	if err = s.executeOperation(ctx, proposal.Operation, proposal.Payload); err != nil {
+		return err
+	}
+	if err = recordProposalAction(ctx.GetStub(), proposal, "execute", ""); err != nil {
+		return err
+	}
+	proposal.Status = ProposalStatusExecuted
+	return s.emitEvent(ctx, events.ProposalExecuted, newProposalEvent(proposal, ""))
+}
+
+// proposalApprover identifies the person behind the submitting certificate.
+// Fabric CA puts the enrollment id into every certificate it issues for a user,
+// so re-enrolled certificates of one person count as a single approval.
+// Certificates without it fall back to the certificate id
+func proposalApprover(stub shim.ChaincodeStubInterface) (string, error) {
+	mspId, clientId, err := getClientIdentity(stub)
+	if err != nil {
+		return "", err
+	}
+	enrollmentId, found, err := cid.GetAttributeValue(stub, enrollmentIdAttribute)
+	if err != nil {
+		return "", err
+	}
+	if found && enrollmentId != "" {
+		return mspId + "::" + enrollmentIdAttribute + "=" + enrollmentId, nil
+	}
+	return mspId + "::" + clientId, nil
+}
+
+func newProposalEvent(proposal *Proposal, reason string) events.Proposal {
+	return events.Proposal{
+		ID:        proposal.ID,
+		Operation: proposal.Operation,
+		Payload:   proposal.Payload,
+		Status:    proposal.Status,
+		Threshold: proposal.Threshold,
+		Approvals: len(proposal.Approvers),
+		Reason:    reason,
+	}
+}
+
+func recordProposalAction(stub shim.ChaincodeStubInterface, proposal *Proposal, action string, reason string) error {
+	mspId, clientId, err := getClientIdentity(stub)
+	if err != nil {
+		return err
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	proposal.History = append(proposal.History, &ProposalAction{
+		Action:      action,
+		MSPID:       mspId,
+		ClientID:    clientId,
+		Reason:      reason,
+		TxID:        stub.GetTxID(),
+		TxTimestamp: ts.AsTime().Unix(),
+	})
+	proposal.UpdateTxTimestamp = ts.AsTime().Unix()
+	return nil
+}
+
+func putProposal(stub shim.ChaincodeStubInterface, stateId string, proposal *Proposal) error {
+	proposalJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(stateId, proposalJSON)
+}
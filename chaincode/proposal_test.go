@@ -0,0 +1,179 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func newEnrolledSuperAdmin(t *testing.T, commonName string, enrollmentId string) []byte {
+	return newIdentity(t, "DsolutionsOrgMSP", commonName, map[string]string{"diplom-mn.admin": "true", "hf.EnrollmentID": enrollmentId})
+}
+
+func TestProposalThreshold(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	alice := newEnrolledSuperAdmin(t, "alice", "alice")
+	bob := newEnrolledSuperAdmin(t, "bob", "bob")
+	createOrg(t, l, alice, "ORG1")
+	org, err := sc.ReadOrg(l.as(alice), "ORG1")
+	require.NoError(t, err)
+
+	proposal, err := sc.ProposeMint(l.as(alice), org.OrgCreditID, "ORG1", "10", "grant")
+	require.NoError(t, err)
+	require.Equal(t, chaincode.ProposalStatusPending, proposal.Status)
+	require.Equal(t, 2, proposal.Threshold)
+	require.Len(t, proposal.Approvers, 1)
+
+	credit, err := sc.ReadCredit(l.as(alice), org.OrgCreditID, "ORG1")
+	require.NoError(t, err)
+	require.True(t, decimal.RequireFromString(credit.Amount).IsZero())
+
+	proposal, err = sc.ApproveProposal(l.as(bob), proposal.ID)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.ProposalStatusExecuted, proposal.Status)
+	credit, err = sc.ReadCredit(l.as(alice), org.OrgCreditID, "ORG1")
+	require.NoError(t, err)
+	require.True(t, decimal.RequireFromString("10").Equal(decimal.RequireFromString(credit.Amount)))
+
+	_, err = sc.ApproveProposal(l.as(bob), proposal.ID)
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+}
+
+func TestProposalDuplicateApprover(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	alice := newEnrolledSuperAdmin(t, "alice", "alice")
+	createOrg(t, l, alice, "ORG1")
+	org, err := sc.ReadOrg(l.as(alice), "ORG1")
+	require.NoError(t, err)
+	proposal, err := sc.ProposeMint(l.as(alice), org.OrgCreditID, "ORG1", "10", "grant")
+	require.NoError(t, err)
+
+	// the same certificate twice
+	_, err = sc.ApproveProposal(l.as(alice), proposal.ID)
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+
+	// a second certificate enrolled for the same person
+	reenrolled := newEnrolledSuperAdmin(t, "alice-laptop", "alice")
+	_, err = sc.ApproveProposal(l.as(reenrolled), proposal.ID)
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+
+	proposal, err = sc.ReadProposal(l.as(alice), proposal.ID)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.ProposalStatusPending, proposal.Status)
+	require.Len(t, proposal.Approvers, 1)
+}
+
+func TestListProposalsStatusFilter(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	var queries []string
+	l.stub.GetQueryResultStub = func(query string) (shim.StateQueryIteratorInterface, error) {
+		queries = append(queries, query)
+		return newIterator(nil, l.state), nil
+	}
+
+	_, err := sc.ListProposals(l.as(su), "")
+	require.NoError(t, err)
+	_, err = sc.ListProposals(l.as(su), chaincode.ProposalStatusPending)
+	require.NoError(t, err)
+	_, err = sc.ListProposals(l.as(su), "unknown")
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+
+	require.Len(t, queries, 2)
+	require.NotContains(t, queries[0], `"status"`)
+	require.Contains(t, queries[0], "proposal-index-2")
+	require.Contains(t, queries[1], `"status":{"$eq":"pending"}`)
+	require.Contains(t, queries[1], "proposal-index-1")
+}
+
+func proposalActions(proposal *chaincode.Proposal) []string {
+	actions := make([]string, 0, len(proposal.History))
+	for _, action := range proposal.History {
+		actions = append(actions, action.Action)
+	}
+	return actions
+}
+
+func TestProposeBurn(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	alice := newEnrolledSuperAdmin(t, "alice", "alice")
+	bob := newEnrolledSuperAdmin(t, "bob", "bob")
+	createOrg(t, l, alice, "ORG1")
+	org, err := sc.ReadOrg(l.as(alice), "ORG1")
+	require.NoError(t, err)
+	proposal, err := sc.ProposeMint(l.as(alice), org.OrgCreditID, "ORG1", "10", "grant")
+	require.NoError(t, err)
+	_, err = sc.ApproveProposal(l.as(bob), proposal.ID)
+	require.NoError(t, err)
+
+	proposal, err = sc.ProposeBurn(l.as(bob), org.OrgCreditID, "ORG1", "4", "correction")
+	require.NoError(t, err)
+	require.Equal(t, chaincode.OperationCreditBurn, proposal.Operation)
+	proposal, err = sc.ApproveProposal(l.as(alice), proposal.ID)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.ProposalStatusExecuted, proposal.Status)
+	require.Equal(t, []string{"propose", "approve", "approve", "execute"}, proposalActions(proposal))
+
+	credit, err := sc.ReadCredit(l.as(alice), org.OrgCreditID, "ORG1")
+	require.NoError(t, err)
+	require.True(t, decimal.RequireFromString("6").Equal(decimal.RequireFromString(credit.Amount)))
+}
+
+func TestRejectProposal(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	alice := newEnrolledSuperAdmin(t, "alice", "alice")
+	bob := newEnrolledSuperAdmin(t, "bob", "bob")
+	createOrg(t, l, alice, "ORG1")
+	org, err := sc.ReadOrg(l.as(alice), "ORG1")
+	require.NoError(t, err)
+	proposal, err := sc.ProposeMint(l.as(alice), org.OrgCreditID, "ORG1", "10", "grant")
+	require.NoError(t, err)
+
+	proposal, err = sc.RejectProposal(l.as(bob), proposal.ID, "not agreed")
+	require.NoError(t, err)
+	require.Equal(t, chaincode.ProposalStatusRejected, proposal.Status)
+	require.Equal(t, []string{"propose", "approve", "reject"}, proposalActions(proposal))
+	require.Equal(t, "not agreed", proposal.History[2].Reason)
+
+	_, err = sc.ApproveProposal(l.as(bob), proposal.ID)
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+	credit, err := sc.ReadCredit(l.as(alice), org.OrgCreditID, "ORG1")
+	require.NoError(t, err)
+	require.True(t, decimal.RequireFromString(credit.Amount).IsZero())
+}
+
+func TestExpireProposal(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	alice := newEnrolledSuperAdmin(t, "alice", "alice")
+	bob := newEnrolledSuperAdmin(t, "bob", "bob")
+	createOrg(t, l, alice, "ORG1")
+	org, err := sc.ReadOrg(l.as(alice), "ORG1")
+	require.NoError(t, err)
+	proposal, err := sc.ProposeMint(l.as(alice), org.OrgCreditID, "ORG1", "10", "grant")
+	require.NoError(t, err)
+
+	_, err = sc.ExpireProposal(l.as(bob), proposal.ID)
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+
+	l.now = time.Unix(proposal.ExpiresAt, 0)
+	read, err := sc.ReadProposal(l.as(bob), proposal.ID)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.ProposalStatusExpired, read.Status)
+	_, err = sc.ApproveProposal(l.as(bob), proposal.ID)
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+
+	proposal, err = sc.ExpireProposal(l.as(bob), proposal.ID)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.ProposalStatusExpired, proposal.Status)
+	require.Equal(t, []string{"propose", "approve", "expire"}, proposalActions(proposal))
+}
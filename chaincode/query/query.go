@@ -0,0 +1,135 @@
+// Package query builds CouchDB Mango queries as Go values serialized with
+// encoding/json, so values taken from transaction arguments can never change
+// the structure of a query.
+//
+//	queryString, err := query.New().
+//		Where("docType", "Organization").
+//		WhereOp("createTxTimestamp", query.Gte, from).
+//		Sort("createTxTimestamp", query.Asc).
+//		UseIndex("org-index-2", "org-index-2").
+//		String()
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Selector operators
+const (
+	Eq     = "$eq"
+	Ne     = "$ne"
+	Gt     = "$gt"
+	Gte    = "$gte"
+	Lt     = "$lt"
+	Lte    = "$lte"
+	In     = "$in"
+	Nin    = "$nin"
+	Exists = "$exists"
+	Regex  = "$regex"
+)
+
+// Sort directions
+const (
+	Asc  = "asc"
+	Desc = "desc"
+)
+
+var operators = map[string]bool{Eq: true, Ne: true, Gt: true, Gte: true, Lt: true, Lte: true, In: true, Nin: true, Exists: true, Regex: true}
+
+// Query is a Mango query. Build it with New and the chained methods, the first
+// invalid call is reported by String
+type Query struct {
+	selector map[string]map[string]interface{}
+	sort     []map[string]string
+	fields   []string
+	limit    int
+	useIndex []string
+	err      error
+}
+
+type mangoQuery struct {
+	Selector map[string]map[string]interface{} `json:"selector"`
+	Sort     []map[string]string               `json:"sort,omitempty"`
+	Fields   []string                          `json:"fields,omitempty"`
+	Limit    int                               `json:"limit,omitempty"`
+	UseIndex []string                          `json:"use_index,omitempty"`
+}
+
+func New() *Query {
+	return &Query{selector: map[string]map[string]interface{}{}}
+}
+
+// Where adds an equality condition on field
+func (q *Query) Where(field string, value interface{}) *Query {
+	return q.WhereOp(field, Eq, value)
+}
+
+// WhereOp adds a condition on field. Conditions on the same field are combined
+func (q *Query) WhereOp(field string, op string, value interface{}) *Query {
+	if q.err != nil {
+		return q
+	}
+	if field == "" {
+		q.err = fmt.Errorf("Query field should not be empty")
+		return q
+	}
+	if !operators[op] {
+		q.err = fmt.Errorf("Unsupported query operator %s", op)
+		return q
+	}
+	if _, ok := q.selector[field]; !ok {
+		q.selector[field] = map[string]interface{}{}
+	}
+	q.selector[field][op] = value
+	return q
+}
+
+func (q *Query) Sort(field string, direction string) *Query {
+	if q.err != nil {
+		return q
+	}
+	if direction != Asc && direction != Desc {
+		q.err = fmt.Errorf("Sort direction should be either of asc or desc")
+		return q
+	}
+	q.sort = append(q.sort, map[string]string{field: direction})
+	return q
+}
+
+func (q *Query) Fields(fields ...string) *Query {
+	q.fields = append(q.fields, fields...)
+	return q
+}
+
+func (q *Query) Limit(limit int) *Query {
+	if q.err == nil && limit < 0 {
+		q.err = fmt.Errorf("Query limit should not be negative")
+	}
+	q.limit = limit
+	return q
+}
+
+// UseIndex pins the query to the index name of the design document ddoc
+func (q *Query) UseIndex(ddoc string, name string) *Query {
+	q.useIndex = []string{ddoc, name}
+	return q
+}
+
+// String serializes the query
+func (q *Query) String() (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	queryJSON, err := json.Marshal(mangoQuery{
+		Selector: q.selector,
+		Sort:     q.sort,
+		Fields:   q.fields,
+		Limit:    q.limit,
+		UseIndex: q.useIndex,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(queryJSON), nil
+}
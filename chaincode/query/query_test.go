@@ -0,0 +1,72 @@
+package query_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryString(t *testing.T) {
+	queryString, err := query.New().
+		Where("docType", "Organization").
+		WhereOp("createTxTimestamp", query.Gte, 10).
+		WhereOp("createTxTimestamp", query.Lt, 20).
+		Sort("createTxTimestamp", query.Desc).
+		Sort("id", query.Asc).
+		Fields("id", "name").
+		Limit(5).
+		UseIndex("org-index-2", "org-index-2").
+		String()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"selector":{"docType":{"$eq":"Organization"},"createTxTimestamp":{"$gte":10,"$lt":20}},
+		"sort":[{"createTxTimestamp":"desc"},{"id":"asc"}],
+		"fields":["id","name"],
+		"limit":5,
+		"use_index":["org-index-2","org-index-2"]}`, queryString)
+}
+
+func TestQueryOmitsUnsetParts(t *testing.T) {
+	queryString, err := query.New().String()
+	require.NoError(t, err)
+	require.Equal(t, `{"selector":{}}`, queryString)
+}
+
+func TestQueryEscapesValues(t *testing.T) {
+	values := []string{
+		`ORG1"},"docType":{"$ne":"x`,
+		`ORG1\`,
+		"ORG1\n\u0000",
+		`{"$gt":null}`,
+	}
+	for _, value := range values {
+		queryString, err := query.New().Where("orgId", value).WhereOp("name", query.In, []string{value}).String()
+		require.NoError(t, err)
+		var parsed map[string]map[string]map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(queryString), &parsed))
+		require.Equal(t, map[string]map[string]interface{}{
+			"orgId": {"$eq": value},
+			"name":  {"$in": []interface{}{value}},
+		}, parsed["selector"])
+	}
+}
+
+func TestQueryRejectsInvalidCalls(t *testing.T) {
+	tests := map[string]*query.Query{
+		"Unsupported query operator $where":              query.New().WhereOp("id", "$where", "1"),
+		"Unsupported query operator $or":                 query.New().WhereOp("id", "$or", []string{}),
+		"Query field should not be empty":                query.New().Where("", "1"),
+		"Sort direction should be either of asc or desc": query.New().Sort("id", "up"),
+		"Query limit should not be negative":             query.New().Limit(-1),
+	}
+	for message, q := range tests {
+		_, err := q.Where("docType", "Organization").String()
+		require.EqualError(t, err, message)
+	}
+
+	// the first invalid call is reported
+	_, err := query.New().WhereOp("id", "$where", "1").Sort("id", "up").String()
+	require.EqualError(t, err, "Unsupported query operator $where")
+}
@@ -0,0 +1,64 @@
+package chaincode
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Environment of the chaincode server mode, the same variables contractapi reads
+const (
+	serverAddressVariable = "CHAINCODE_SERVER_ADDRESS"
+	chaincodeIdVariable   = "CORE_CHAINCODE_ID_NAME"
+	tlsEnabledVariable    = "CORE_PEER_TLS_ENABLED"
+	rootCertVariable      = "CORE_PEER_TLS_ROOTCERT_FILE"
+	clientKeyVariable     = "CORE_TLS_CLIENT_KEY_FILE"
+	clientCertVariable    = "CORE_TLS_CLIENT_CERT_FILE"
+)
+
+// chaincodeServerConfig returns nil when the chaincode is not run as a server
+func chaincodeServerConfig() (*shim.ChaincodeServer, error) {
+	address := os.Getenv(serverAddressVariable)
+	ccid := os.Getenv(chaincodeIdVariable)
+	if address == "" || ccid == "" {
+		return nil, nil
+	}
+	tlsProps, err := chaincodeServerTLSProperties()
+	if err != nil {
+		return nil, err
+	}
+	return &shim.ChaincodeServer{
+		CCID:     ccid,
+		Address:  address,
+		TLSProps: *tlsProps,
+	}, nil
+}
+
+func chaincodeServerTLSProperties() (*shim.TLSProperties, error) {
+	tlsEnabled, err := strconv.ParseBool(os.Getenv(tlsEnabledVariable))
+	if err != nil || !tlsEnabled {
+		return &shim.TLSProperties{Disabled: true}, nil
+	}
+	keyBytes, err := os.ReadFile(os.Getenv(clientKeyVariable))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the chaincode server key - %s", err)
+	}
+	certBytes, err := os.ReadFile(os.Getenv(clientCertVariable))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the chaincode server certificate - %s", err)
+	}
+	var rootBytes []byte
+	if root := os.Getenv(rootCertVariable); root != "" {
+		if rootBytes, err = os.ReadFile(root); err != nil {
+			return nil, fmt.Errorf("Error reading the client root certificate - %s", err)
+		}
+	}
+	return &shim.TLSProperties{
+		Disabled:      false,
+		Key:           keyBytes,
+		Cert:          certBytes,
+		ClientCACerts: rootBytes,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package chaincode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestChaincodeServerConfig(t *testing.T) {
+	for _, name := range []string{serverAddressVariable, chaincodeIdVariable, tlsEnabledVariable, rootCertVariable, clientKeyVariable, clientCertVariable} {
+		t.Setenv(name, "")
+	}
+	server, err := chaincodeServerConfig()
+	require.NoError(t, err)
+	require.Nil(t, server)
+	t.Setenv(serverAddressVariable, "0.0.0.0:9999")
+	server, err = chaincodeServerConfig()
+	require.NoError(t, err)
+	require.Nil(t, server)
+
+	t.Setenv(chaincodeIdVariable, "organization:abc")
+	server, err = chaincodeServerConfig()
+	require.NoError(t, err)
+	require.Equal(t, "organization:abc", server.CCID)
+	require.Equal(t, "0.0.0.0:9999", server.Address)
+	require.True(t, server.TLSProps.Disabled)
+	t.Setenv(tlsEnabledVariable, "false")
+	server, err = chaincodeServerConfig()
+	require.NoError(t, err)
+	require.True(t, server.TLSProps.Disabled)
+
+	t.Setenv(tlsEnabledVariable, "true")
+	_, err = chaincodeServerConfig()
+	require.ErrorContains(t, err, "server key")
+	t.Setenv(clientKeyVariable, writeFile(t, "client.key", "key"))
+	_, err = chaincodeServerConfig()
+	require.ErrorContains(t, err, "server certificate")
+	t.Setenv(clientCertVariable, writeFile(t, "client.crt", "cert"))
+	server, err = chaincodeServerConfig()
+	require.NoError(t, err)
+	require.False(t, server.TLSProps.Disabled)
+	require.Equal(t, []byte("key"), server.TLSProps.Key)
+	require.Equal(t, []byte("cert"), server.TLSProps.Cert)
+	require.Nil(t, server.TLSProps.ClientCACerts)
+
+	t.Setenv(rootCertVariable, filepath.Join(t.TempDir(), "missing.crt"))
+	_, err = chaincodeServerConfig()
+	require.ErrorContains(t, err, "root certificate")
+	t.Setenv(rootCertVariable, writeFile(t, "root.crt", "root"))
+	server, err = chaincodeServerConfig()
+	require.NoError(t, err)
+	require.Equal(t, []byte("root"), server.TLSProps.ClientCACerts)
+}
@@ -0,0 +1,333 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// OrgSigningRequirement is a co-signing org that has to sign Keys of every
+// document issued by the policy org
+type OrgSigningRequirement struct {
+	OrgID string   `json:"orgId" validate:"required,id"`
+	Keys  []string `json:"keys" validate:"required,min=1,max=32,dive,required,max=256"`
+}
+
+// OrgSigningPolicyInput is the signing policy of an org. Keys are dot separated
+// JSON paths of the document, e.g. claims.extras.hemisDiploma. Signers other
+// than the required co-signers must be trusted for TrustPurpose
+type OrgSigningPolicyInput struct {
+	SignedKeys        []string                `json:"signedKeys" validate:"required,min=1,max=32,dive,required,max=256"`
+	RequiredCoSigners []OrgSigningRequirement `json:"requiredCoSigners,omitempty" metadata:",optional" validate:"max=16,dive"`
+	MinSigners        int                     `json:"minSigners" validate:"min=1,max=16"`
+	TrustPurpose      string                  `json:"trustPurpose,omitempty" metadata:",optional" validate:"omitempty,id"`
+}
+
+type OrgSigningPolicy struct {
+	DocType           string                  `json:"docType"`
+	OrgID             string                  `json:"orgId"`
+	SignedKeys        []string                `json:"signedKeys"`
+	RequiredCoSigners []OrgSigningRequirement `json:"requiredCoSigners"`
+	MinSigners        int                     `json:"minSigners"`
+	TrustPurpose      string                  `json:"trustPurpose,omitempty" metadata:",optional"`
+	UpdatedBy         Actor                   `json:"updatedBy" metadata:",optional"`
+	UpdateTxTimestamp int64                   `json:"updateTxTimestamp"`
+}
+
+// SigningBundleValidation is the result of ValidateSigningBundle, Problems
+// lists every check the document failed
+type SigningBundleValidation struct {
+	Valid       bool     `json:"valid"`
+	IssuerOrgID string   `json:"issuerOrgId"`
+	Signers     []string `json:"signers"`
+	Problems    []string `json:"problems"`
+}
+
+type orgSignProp struct {
+	Key string `json:"key"`
+}
+
+// signingBundle is the signing part of a document, signOrgID starts with the
+// issuing org
+type signingBundle struct {
+	SignOrgIDs   []string                 `json:"signOrgID"`
+	OrgSignProps map[string][]orgSignProp `json:"orgSignProps"`
+}
+
+func (s *SmartContract) SetOrgSigningPolicy(ctx contractapi.TransactionContextInterface, orgId string, input OrgSigningPolicyInput) (*OrgSigningPolicy, error) {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return nil, err
+	}
+	if err := validateInput(&input); err != nil {
+		return nil, err
+	}
+	org, err := s.readOrg(ctx.GetStub(), orgId)
+	if err != nil {
+		return nil, err
+	}
+	if err = assertOrgNotArchived(org); err != nil {
+		return nil, err
+	}
+	coSignerIds := make([]string, 0, len(input.RequiredCoSigners))
+	for _, requirement := range input.RequiredCoSigners {
+		if requirement.OrgID == orgId || containsString(coSignerIds, requirement.OrgID) {
+			return nil, newValidationError("requiredCoSigners should not contain %s twice or the org itself", requirement.OrgID)
+		}
+		exists, err := s.OrgExists(ctx, requirement.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, newNotFoundError("Org %s does not exist", requirement.OrgID)
+		}
+		coSignerIds = append(coSignerIds, requirement.OrgID)
+	}
+	if input.MinSigners < len(coSignerIds)+1 {
+		return nil, newValidationError("minSigners should be at least %d", len(coSignerIds)+1)
+	}
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	actor, err := s.newActor(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	requiredCoSigners := input.RequiredCoSigners
+	if requiredCoSigners == nil {
+		requiredCoSigners = []OrgSigningRequirement{}
+	}
+	policy := &OrgSigningPolicy{
+		DocType:           "OrgSigningPolicy",
+		OrgID:             orgId,
+		SignedKeys:        input.SignedKeys,
+		RequiredCoSigners: requiredCoSigners,
+		MinSigners:        input.MinSigners,
+		TrustPurpose:      input.TrustPurpose,
+		UpdatedBy:         actor,
+		UpdateTxTimestamp: ts.AsTime().Unix(),
+	}
+	stateId, err := s.newOrgSigningPolicyStateId(ctx.GetStub(), orgId)
+	if err != nil {
+		return nil, err
+	}
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.GetStub().PutState(stateId, policyJSON); err != nil {
+		return nil, err
+	}
+	event := events.OrgSigningPolicy{
+		OrgID:        orgId,
+		SignedKeys:   policy.SignedKeys,
+		CoSignerIDs:  coSignerIds,
+		MinSigners:   policy.MinSigners,
+		TrustPurpose: policy.TrustPurpose,
+	}
+	if err = s.emitEvent(ctx, events.OrgSigningPolicySet, event); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (s *SmartContract) ReadOrgSigningPolicy(ctx contractapi.TransactionContextInterface, orgId string) (*OrgSigningPolicy, error) {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return nil, err
+	}
+	policy, err := s.readOrgSigningPolicy(ctx.GetStub(), orgId)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, newNotFoundError("Org %s has no signing policy", orgId)
+	}
+	return policy, nil
+}
+
+// ValidateSigningBundle checks the signOrgID and orgSignProps of a document
+// against the signing policies of the issuing org and its co-signers
+func (s *SmartContract) ValidateSigningBundle(ctx contractapi.TransactionContextInterface, documentJSON string) (*SigningBundleValidation, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal([]byte(documentJSON), &document); err != nil {
+		return nil, newValidationError("documentJSON is not a JSON object")
+	}
+	var bundle signingBundle
+	if err := json.Unmarshal([]byte(documentJSON), &bundle); err != nil {
+		return nil, newValidationError("signOrgID or orgSignProps is malformed")
+	}
+	problems, err := s.signingBundleProblems(ctx.GetStub(), document, &bundle)
+	if err != nil {
+		return nil, err
+	}
+	result := &SigningBundleValidation{
+		Valid:    len(problems) == 0,
+		Signers:  bundle.SignOrgIDs,
+		Problems: problems,
+	}
+	if result.Signers == nil {
+		result.Signers = []string{}
+	}
+	if len(bundle.SignOrgIDs) > 0 {
+		result.IssuerOrgID = bundle.SignOrgIDs[0]
+	}
+	return result, nil
+}
+
+func (s *SmartContract) signingBundleProblems(stub shim.ChaincodeStubInterface, document map[string]interface{}, bundle *signingBundle) ([]string, error) {
+	problems := make([]string, 0)
+	if len(bundle.SignOrgIDs) == 0 {
+		return append(problems, "signOrgID is empty"), nil
+	}
+	issuerOrgId := bundle.SignOrgIDs[0]
+	issuerPolicy, err := s.readOrgSigningPolicy(stub, issuerOrgId)
+	if err != nil {
+		return nil, err
+	}
+	if issuerPolicy == nil {
+		return append(problems, fmt.Sprintf("Org %s has no signing policy", issuerOrgId)), nil
+	}
+	if len(bundle.SignOrgIDs) < issuerPolicy.MinSigners {
+		problems = append(problems, fmt.Sprintf("Document needs at least %d signers", issuerPolicy.MinSigners))
+	}
+	propOrgIds := make([]string, 0, len(bundle.OrgSignProps))
+	for orgId := range bundle.OrgSignProps {
+		propOrgIds = append(propOrgIds, orgId)
+	}
+	sort.Strings(propOrgIds)
+	for _, orgId := range propOrgIds {
+		if !containsString(bundle.SignOrgIDs, orgId) {
+			problems = append(problems, fmt.Sprintf("Org %s has orgSignProps but is not in signOrgID", orgId))
+		}
+	}
+	for i, orgId := range bundle.SignOrgIDs {
+		if containsString(bundle.SignOrgIDs[:i], orgId) {
+			problems = append(problems, fmt.Sprintf("Org %s is in signOrgID twice", orgId))
+			continue
+		}
+		org, err := s.readOrg(stub, orgId)
+		if err != nil && ErrorCodeOf(err) != ErrorCodeNotFound {
+			return nil, err
+		}
+		if org == nil || org.ArchivedAt > 0 || !org.IsActive {
+			problems = append(problems, fmt.Sprintf("Org %s can not sign", orgId))
+			continue
+		}
+		props := bundle.OrgSignProps[orgId]
+		if len(props) == 0 {
+			problems = append(problems, fmt.Sprintf("Org %s has no orgSignProps", orgId))
+			continue
+		}
+		allowedKeys := issuerPolicy.SignedKeys
+		if i > 0 {
+			if allowedKeys, err = s.coSignerAllowedKeys(stub, issuerPolicy, orgId); err != nil {
+				return nil, err
+			}
+			if !isRequiredCoSigner(issuerPolicy, orgId) {
+				trusted := false
+				if issuerPolicy.TrustPurpose != "" {
+					if trusted, err = s.isTrustedCoSigner(stub, issuerOrgId, orgId, issuerPolicy.TrustPurpose); err != nil {
+						return nil, err
+					}
+				}
+				if !trusted {
+					problems = append(problems, fmt.Sprintf("Org %s is not a trusted co-signer of %s", orgId, issuerOrgId))
+				}
+			}
+		}
+		for _, prop := range props {
+			if _, found := documentValueAt(document, prop.Key); !found {
+				problems = append(problems, fmt.Sprintf("Key %s signed by %s is not in the document", prop.Key, orgId))
+			}
+			if !keyCoveredBy(prop.Key, allowedKeys) {
+				problems = append(problems, fmt.Sprintf("Org %s is not allowed to sign %s", orgId, prop.Key))
+			}
+		}
+	}
+	for _, requirement := range issuerPolicy.RequiredCoSigners {
+		signedKeys := make([]string, 0, len(bundle.OrgSignProps[requirement.OrgID]))
+		for _, prop := range bundle.OrgSignProps[requirement.OrgID] {
+			signedKeys = append(signedKeys, prop.Key)
+		}
+		for _, key := range requirement.Keys {
+			if !containsString(bundle.SignOrgIDs, requirement.OrgID) || !keyCoveredBy(key, signedKeys) {
+				problems = append(problems, fmt.Sprintf("Org %s has to sign %s", requirement.OrgID, key))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// coSignerAllowedKeys returns the keys a co-signer may sign, those of its own
+// signing policy or, without one, only the keys the issuer requires of it
+func (s *SmartContract) coSignerAllowedKeys(stub shim.ChaincodeStubInterface, issuerPolicy *OrgSigningPolicy, orgId string) ([]string, error) {
+	policy, err := s.readOrgSigningPolicy(stub, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		return policy.SignedKeys, nil
+	}
+	for _, requirement := range issuerPolicy.RequiredCoSigners {
+		if requirement.OrgID == orgId {
+			return requirement.Keys, nil
+		}
+	}
+	return []string{}, nil
+}
+
+func isRequiredCoSigner(policy *OrgSigningPolicy, orgId string) bool {
+	for _, requirement := range policy.RequiredCoSigners {
+		if requirement.OrgID == orgId {
+			return true
+		}
+	}
+	return false
+}
+
+// keyCoveredBy tells whether key is one of keys or nested below one of them
+func keyCoveredBy(key string, keys []string) bool {
+	for _, k := range keys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// documentValueAt looks up a dot separated path in a decoded JSON document
+func documentValueAt(document map[string]interface{}, key string) (interface{}, bool) {
+	var value interface{} = document
+	for _, part := range strings.Split(key, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// readOrgSigningPolicy returns nil when the org has no signing policy
+func (s *SmartContract) readOrgSigningPolicy(stub shim.ChaincodeStubInterface, orgId string) (*OrgSigningPolicy, error) {
+	stateId, err := s.newOrgSigningPolicyStateId(stub, orgId)
+	if err != nil {
+		return nil, err
+	}
+	policyJSON, err := stub.GetState(stateId)
+	if err != nil || policyJSON == nil {
+		return nil, err
+	}
+	var policy OrgSigningPolicy
+	if err = json.Unmarshal(policyJSON, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
@@ -0,0 +1,89 @@
+package chaincode_test
+
+import (
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+// newSigningLedger has ORG1 issuing documents co-signed by ORG2, required for
+// claims.extras.hemis, and by ORG3 and ORG4, trusted for diploma. Only ORG1
+// and ORG4 have signing policies
+func newSigningLedger(t *testing.T) (*ledger, []byte) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	for _, orgId := range []string{"ORG1", "ORG2", "ORG3", "ORG4", "ORG5"} {
+		createOrg(t, l, su, orgId)
+	}
+	_, err := sc.SetOrgSigningPolicy(l.as(su), "ORG1", chaincode.OrgSigningPolicyInput{
+		SignedKeys:        []string{"claims"},
+		RequiredCoSigners: []chaincode.OrgSigningRequirement{{OrgID: "ORG2", Keys: []string{"claims.extras.hemis"}}},
+		MinSigners:        2,
+		TrustPurpose:      "diploma",
+	})
+	require.NoError(t, err)
+	_, err = sc.SetOrgSigningPolicy(l.as(su), "ORG4", chaincode.OrgSigningPolicyInput{SignedKeys: []string{"claims.grades"}, MinSigners: 1})
+	require.NoError(t, err)
+	for _, orgId := range []string{"ORG3", "ORG4"} {
+		_, err = sc.TrustOrg(l.as(su), "ORG1", orgId, "diploma", l.now.Unix(), l.now.Unix()+3600)
+		require.NoError(t, err)
+	}
+	return l, su
+}
+
+const signedDocument = `{"claims":{"name":"Bat","grades":{"math":"A"},"extras":{"hemis":"123"}},`
+
+func validateBundle(t *testing.T, l *ledger, su []byte, bundle string) *chaincode.SigningBundleValidation {
+	result, err := (&chaincode.SmartContract{}).ValidateSigningBundle(l.as(su), signedDocument+bundle)
+	require.NoError(t, err)
+	return result
+}
+
+func TestValidateSigningBundle(t *testing.T) {
+	l, su := newSigningLedger(t)
+
+	result := validateBundle(t, l, su, `"signOrgID":["ORG1","ORG2","ORG4"],"orgSignProps":{
+		"ORG1":[{"key":"claims.name"}],"ORG2":[{"key":"claims.extras.hemis"}],"ORG4":[{"key":"claims.grades.math"}]}}`)
+	require.Empty(t, result.Problems)
+	require.True(t, result.Valid)
+	require.Equal(t, "ORG1", result.IssuerOrgID)
+}
+
+func TestValidateSigningBundleScope(t *testing.T) {
+	l, su := newSigningLedger(t)
+
+	// ORG2 has no policy and may only sign what ORG1 requires of it, ORG3 has
+	// no policy and is not required so it may sign nothing, ORG4 is limited
+	// to its own policy
+	result := validateBundle(t, l, su, `"signOrgID":["ORG1","ORG2","ORG3","ORG4"],"orgSignProps":{
+		"ORG1":[{"key":"claims.name"}],
+		"ORG2":[{"key":"claims.extras.hemis"},{"key":"claims.name"}],
+		"ORG3":[{"key":"claims.grades"}],
+		"ORG4":[{"key":"claims.name"}]}}`)
+	require.False(t, result.Valid)
+	require.Equal(t, []string{
+		"Org ORG2 is not allowed to sign claims.name",
+		"Org ORG3 is not allowed to sign claims.grades",
+		"Org ORG4 is not allowed to sign claims.name",
+	}, result.Problems)
+}
+
+func TestValidateSigningBundleIssuerAndSigners(t *testing.T) {
+	l, su := newSigningLedger(t)
+
+	result := validateBundle(t, l, su, `"signOrgID":["ORG2","ORG1"],"orgSignProps":{"ORG2":[{"key":"claims.extras.hemis"}],"ORG1":[{"key":"claims.name"}]}}`)
+	require.False(t, result.Valid)
+	require.Equal(t, "ORG2", result.IssuerOrgID)
+	require.Equal(t, []string{"Org ORG2 has no signing policy"}, result.Problems)
+
+	result = validateBundle(t, l, su, `"signOrgID":["ORG1","ORG5","ORG1"],"orgSignProps":{"ORG1":[{"key":"claims.name"}],"ORG5":[{"key":"claims.name"}]}}`)
+	require.False(t, result.Valid)
+	require.Equal(t, []string{
+		"Org ORG5 is not a trusted co-signer of ORG1",
+		"Org ORG5 is not allowed to sign claims.name",
+		"Org ORG1 is in signOrgID twice",
+		"Org ORG2 has to sign claims.extras.hemis",
+	}, result.Problems)
+}
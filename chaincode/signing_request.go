@@ -0,0 +1,440 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	SigningRequestStatusOpen      = "open"
+	SigningRequestStatusCompleted = "completed"
+	SigningRequestStatusCancelled = "cancelled"
+)
+
+// OrgSignature is the signature of an org over the document hash bytes, KeyID
+// is the fingerprint of the org key it was verified with
+type OrgSignature struct {
+	OrgID       string `json:"orgId"`
+	KeyID       string `json:"keyId"`
+	Signature   string `json:"signature"`
+	SignedBy    Actor  `json:"signedBy"`
+	TxTimestamp int64  `json:"txTimestamp"`
+}
+
+type SigningRequest struct {
+	DocType           string         `json:"docType"`
+	ID                string         `json:"id"`
+	IssuerOrgID       string         `json:"issuerOrgId"`
+	DocumentHash      string         `json:"documentHash"`
+	RequiredSigners   []string       `json:"requiredSigners"`
+	Signatures        []OrgSignature `json:"signatures"`
+	Status            string         `json:"status"`
+	CreatedBy         Actor          `json:"createdBy"`
+	CompletedAt       int64          `json:"completedAt,omitempty" metadata:",optional"`
+	CreateTxTimestamp int64          `json:"createTxTimestamp"`
+	UpdateTxTimestamp int64          `json:"updateTxTimestamp"`
+}
+
+// SignatureBundle is the combined signatures of a completed signing request
+type SignatureBundle struct {
+	RequestID    string         `json:"requestId"`
+	IssuerOrgID  string         `json:"issuerOrgId"`
+	DocumentHash string         `json:"documentHash"`
+	SignOrgIDs   []string       `json:"signOrgID"`
+	Signatures   []OrgSignature `json:"signatures"`
+	CompletedAt  int64          `json:"completedAt"`
+}
+
+// OpenSigningRequest asks requiredSigners to sign documentHash, the hex sha256
+// of a document issued by issuerOrgId. The issuer always signs first, it may be
+// left out of requiredSigners but not listed after another org
+func (s *SmartContract) OpenSigningRequest(ctx contractapi.TransactionContextInterface, issuerOrgId string, documentHash string, requiredSigners []string) (*SigningRequest, error) {
+	documentHash = strings.ToLower(documentHash)
+	if err := validateInput(&signingRequestInput{IssuerOrgID: issuerOrgId, DocumentHash: documentHash, RequiredSigners: requiredSigners}); err != nil {
+		return nil, err
+	}
+	requiredSigners, err := normalizeRequiredSigners(issuerOrgId, requiredSigners)
+	if err != nil {
+		return nil, err
+	}
+	stub := ctx.GetStub()
+	for _, signerOrgId := range requiredSigners {
+		signer, err := s.readOrg(stub, signerOrgId)
+		if err != nil {
+			return nil, err
+		}
+		if err = assertOrgNotArchived(signer); err != nil {
+			return nil, err
+		}
+		if !signer.IsActive {
+			return nil, newConflictError("Org %s is not active", signerOrgId)
+		}
+		if signer.PubKeyPem == "" {
+			return nil, newConflictError("Org %s has no public key", signerOrgId)
+		}
+	}
+	policy, err := s.readOrgSigningPolicy(stub, issuerOrgId)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		for _, requirement := range policy.RequiredCoSigners {
+			if !containsString(requiredSigners, requirement.OrgID) {
+				return nil, newValidationError("requiredSigners should contain %s", requirement.OrgID)
+			}
+		}
+		if len(requiredSigners) < policy.MinSigners {
+			return nil, newValidationError("requiredSigners should contain at least %d orgs", policy.MinSigners)
+		}
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	actor, err := s.newActor(stub)
+	if err != nil {
+		return nil, err
+	}
+	request := &SigningRequest{
+		DocType:           "SigningRequest",
+		ID:                stub.GetTxID(),
+		IssuerOrgID:       issuerOrgId,
+		DocumentHash:      documentHash,
+		RequiredSigners:   requiredSigners,
+		Signatures:        []OrgSignature{},
+		Status:            SigningRequestStatusOpen,
+		CreatedBy:         actor,
+		CreateTxTimestamp: ts.AsTime().Unix(),
+		UpdateTxTimestamp: ts.AsTime().Unix(),
+	}
+	if err = s.putSigningRequest(stub, request); err != nil {
+		return nil, err
+	}
+	for _, signerOrgId := range requiredSigners {
+		indexId, err := s.newSigningRequestSignerIndexId(stub, signerOrgId, request.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err = stub.PutState(indexId, indexValue); err != nil {
+			return nil, err
+		}
+		orgIndexId, err := s.newSigningRequestOrgIndexId(stub, signerOrgId, request.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err = stub.PutState(orgIndexId, indexValue); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.emitEvent(ctx, events.SigningRequestOpened, newSigningRequestEvent(request)); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// SubmitSignature adds the signature of signerOrgId to an open request. The
+// signature is base64 ASN.1 ECDSA over the document hash bytes and is verified
+// against the current key of the org
+func (s *SmartContract) SubmitSignature(ctx contractapi.TransactionContextInterface, signerOrgId string, requestId string, signature string) (*SigningRequest, error) {
+	if err := validateInput(&referenceInput{ID: signerOrgId}); err != nil {
+		return nil, err
+	}
+	if err := validateInput(&signatureInput{RequestID: requestId, Signature: signature}); err != nil {
+		return nil, err
+	}
+	stub := ctx.GetStub()
+	request, err := s.readOpenSigningRequest(stub, requestId)
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(request.RequiredSigners, signerOrgId) {
+		return nil, newPermissionDeniedError("Org %s is not a signer of request %s", signerOrgId, requestId)
+	}
+	if signingRequestSignedBy(request, signerOrgId) {
+		return nil, newConflictError("Org %s already signed request %s", signerOrgId, requestId)
+	}
+	signer, err := s.readOrg(stub, signerOrgId)
+	if err != nil {
+		return nil, err
+	}
+	if err = assertOrgNotArchived(signer); err != nil {
+		return nil, err
+	}
+	if signer.PubKeyPem == "" {
+		return nil, newConflictError("Org %s has no public key", signerOrgId)
+	}
+	pubKey, err := parseOrgPublicKey(signer.PubKeyType, signer.PubKeyPem)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := hex.DecodeString(request.DocumentHash)
+	if err != nil {
+		return nil, newInternalError("Signing request %s has a malformed document hash", requestId)
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, newValidationError("signature is not base64")
+	}
+	if !ecdsa.VerifyASN1(pubKey, hash, signatureBytes) {
+		return nil, newValidationError("Signature does not match the key of org %s", signerOrgId)
+	}
+	keyId, err := publicKeyFingerprint(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return nil, err
+	}
+	actor, err := s.newActor(stub)
+	if err != nil {
+		return nil, err
+	}
+	request.Signatures = append(request.Signatures, OrgSignature{
+		OrgID:       signerOrgId,
+		KeyID:       keyId,
+		Signature:   signature,
+		SignedBy:    actor,
+		TxTimestamp: ts.AsTime().Unix(),
+	})
+	request.UpdateTxTimestamp = ts.AsTime().Unix()
+	completed := len(request.Signatures) == len(request.RequiredSigners)
+	if completed {
+		request.Status = SigningRequestStatusCompleted
+		request.CompletedAt = ts.AsTime().Unix()
+	}
+	if err = s.putSigningRequest(stub, request); err != nil {
+		return nil, err
+	}
+	indexId, err := s.newSigningRequestSignerIndexId(stub, signerOrgId, request.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err = stub.DelState(indexId); err != nil {
+		return nil, err
+	}
+	if completed {
+		if err = s.delSigningRequestOrgIndexes(stub, request); err != nil {
+			return nil, err
+		}
+	}
+	if err = s.emitEvent(ctx, events.SigningRequestSigned, newSigningRequestEvent(request)); err != nil {
+		return nil, err
+	}
+	if completed {
+		if err = s.emitEvent(ctx, events.SigningRequestCompleted, newSigningRequestEvent(request)); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+func (s *SmartContract) CancelSigningRequest(ctx contractapi.TransactionContextInterface, issuerOrgId string, requestId string) error {
+	if err := validateInput(&referenceInput{ID: issuerOrgId}); err != nil {
+		return err
+	}
+	if err := validateInput(&referenceInput{ID: requestId}); err != nil {
+		return err
+	}
+	stub := ctx.GetStub()
+	request, err := s.readOpenSigningRequest(stub, requestId)
+	if err != nil {
+		return err
+	}
+	if request.IssuerOrgID != issuerOrgId {
+		return newNotFoundError("Signing request %s does not belong to org %s", requestId, issuerOrgId)
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+	request.Status = SigningRequestStatusCancelled
+	request.UpdateTxTimestamp = ts.AsTime().Unix()
+	if err = s.putSigningRequest(stub, request); err != nil {
+		return err
+	}
+	for _, signerOrgId := range request.RequiredSigners {
+		indexId, err := s.newSigningRequestSignerIndexId(stub, signerOrgId, request.ID)
+		if err != nil {
+			return err
+		}
+		if err = stub.DelState(indexId); err != nil {
+			return err
+		}
+	}
+	if err = s.delSigningRequestOrgIndexes(stub, request); err != nil {
+		return err
+	}
+	return s.emitEvent(ctx, events.SigningRequestCancelled, newSigningRequestEvent(request))
+}
+
+func (s *SmartContract) ReadSigningRequest(ctx contractapi.TransactionContextInterface, requestId string) (*SigningRequest, error) {
+	if err := validateInput(&referenceInput{ID: requestId}); err != nil {
+		return nil, err
+	}
+	return s.readSigningRequest(ctx.GetStub(), requestId)
+}
+
+// ReadSignatureBundle returns the signatures of a completed request in the
+// order of its required signers
+func (s *SmartContract) ReadSignatureBundle(ctx contractapi.TransactionContextInterface, requestId string) (*SignatureBundle, error) {
+	if err := validateInput(&referenceInput{ID: requestId}); err != nil {
+		return nil, err
+	}
+	request, err := s.readSigningRequest(ctx.GetStub(), requestId)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != SigningRequestStatusCompleted {
+		return nil, newConflictError("Signing request %s is %s", requestId, request.Status)
+	}
+	signatures := make([]OrgSignature, 0, len(request.Signatures))
+	for _, signerOrgId := range request.RequiredSigners {
+		for _, signature := range request.Signatures {
+			if signature.OrgID == signerOrgId {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+	return &SignatureBundle{
+		RequestID:    request.ID,
+		IssuerOrgID:  request.IssuerOrgID,
+		DocumentHash: request.DocumentHash,
+		SignOrgIDs:   request.RequiredSigners,
+		Signatures:   signatures,
+		CompletedAt:  request.CompletedAt,
+	}, nil
+}
+
+// ListPendingSignatures lists the open requests waiting for the signature of an org
+func (s *SmartContract) ListPendingSignatures(ctx contractapi.TransactionContextInterface, orgId string) ([]*SigningRequest, error) {
+	if err := validateInput(&referenceInput{ID: orgId}); err != nil {
+		return nil, err
+	}
+	stub := ctx.GetStub()
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("SigningRequest~signer", []string{orgId})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	requests := make([]*SigningRequest, 0)
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := stub.SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, err
+		}
+		request, err := s.readSigningRequest(stub, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// normalizeRequiredSigners puts the issuer first and drops orgs listed twice
+func normalizeRequiredSigners(issuerOrgId string, requiredSigners []string) ([]string, error) {
+	signers := []string{issuerOrgId}
+	for i, signerOrgId := range requiredSigners {
+		if signerOrgId == issuerOrgId && i > 0 {
+			return nil, newValidationError("requiredSigners should list %s first or not at all", issuerOrgId)
+		}
+		if !containsString(signers, signerOrgId) {
+			signers = append(signers, signerOrgId)
+		}
+	}
+	return signers, nil
+}
+
+// delSigningRequestOrgIndexes deletes the index that keeps the orgs of an open
+// request from being purged
+func (s *SmartContract) delSigningRequestOrgIndexes(stub shim.ChaincodeStubInterface, request *SigningRequest) error {
+	for _, orgId := range request.RequiredSigners {
+		indexId, err := s.newSigningRequestOrgIndexId(stub, orgId, request.ID)
+		if err != nil {
+			return err
+		}
+		if err = stub.DelState(indexId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func signingRequestSignedBy(request *SigningRequest, orgId string) bool {
+	for _, signature := range request.Signatures {
+		if signature.OrgID == orgId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *SmartContract) readSigningRequest(stub shim.ChaincodeStubInterface, id string) (*SigningRequest, error) {
+	stateId, err := s.newSigningRequestStateId(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	requestJSON, err := stub.GetState(stateId)
+	if err != nil {
+		return nil, err
+	}
+	if requestJSON == nil {
+		return nil, newNotFoundError("Signing request %s does not exist", id)
+	}
+	var request SigningRequest
+	if err = json.Unmarshal(requestJSON, &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (s *SmartContract) readOpenSigningRequest(stub shim.ChaincodeStubInterface, id string) (*SigningRequest, error) {
+	request, err := s.readSigningRequest(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != SigningRequestStatusOpen {
+		return nil, newConflictError("Signing request %s is %s", id, request.Status)
+	}
+	return request, nil
+}
+
+func (s *SmartContract) putSigningRequest(stub shim.ChaincodeStubInterface, request *SigningRequest) error {
+	stateId, err := s.newSigningRequestStateId(stub, request.ID)
+	if err != nil {
+		return err
+	}
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(stateId, requestJSON)
+}
+
+func newSigningRequestEvent(request *SigningRequest) events.SigningRequest {
+	signedOrgIds := make([]string, 0, len(request.Signatures))
+	for _, signature := range request.Signatures {
+		signedOrgIds = append(signedOrgIds, signature.OrgID)
+	}
+	return events.SigningRequest{
+		ID:              request.ID,
+		IssuerOrgID:     request.IssuerOrgID,
+		DocumentHash:    request.DocumentHash,
+		RequiredSigners: request.RequiredSigners,
+		SignedOrgIDs:    signedOrgIds,
+		Status:          request.Status,
+	}
+}
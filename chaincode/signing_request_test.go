@@ -0,0 +1,103 @@
+package chaincode_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+const documentHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+// createKeyedOrg creates an active org with a P-384 public key
+func createKeyedOrg(t *testing.T, l *ledger, admin []byte, orgId string) *ecdsa.PrivateKey {
+	createOrg(t, l, admin, orgId)
+	return setOrgKey(t, l, admin, orgId)
+}
+
+func setOrgKey(t *testing.T, l *ledger, admin []byte, orgId string) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pubKeyPem := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, (&chaincode.SmartContract{}).SetOrgPublicKey(l.as(admin), orgId, "ecdsa:P-384", pubKeyPem))
+	return key
+}
+
+func signDocumentHash(t *testing.T, key *ecdsa.PrivateKey) string {
+	hash, err := hex.DecodeString(documentHash)
+	require.NoError(t, err)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, hash)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestOpenSigningRequestSigners(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createKeyedOrg(t, l, su, "ORG1")
+	createKeyedOrg(t, l, su, "ORG2")
+
+	request, err := sc.OpenSigningRequest(l.as(su), "ORG1", strings.ToUpper(documentHash), []string{"ORG2", "ORG2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG2"}, request.RequiredSigners)
+	require.Equal(t, documentHash, request.DocumentHash)
+
+	request, err = sc.OpenSigningRequest(l.as(su), "ORG1", documentHash, []string{"ORG1", "ORG2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"ORG1", "ORG2"}, request.RequiredSigners)
+
+	_, err = sc.OpenSigningRequest(l.as(su), "ORG1", documentHash, []string{"ORG2", "ORG1"})
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+}
+
+func TestOpenSigningRequestInactiveSigner(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createKeyedOrg(t, l, su, "ORG1")
+	createKeyedOrg(t, l, su, "ORG2")
+	_, err := sc.UpdateOrgWithInput(l.as(su), chaincode.UpdateOrgInput{OrgID: "ORG2", Status: chaincode.OrgStatusInactive})
+	require.NoError(t, err)
+
+	_, err = sc.OpenSigningRequest(l.as(su), "ORG1", documentHash, []string{"ORG2"})
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+	_, err = sc.OpenSigningRequest(l.as(su), "ORG2", documentHash, []string{"ORG1"})
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+}
+
+func TestSignatureBundle(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	key1 := createKeyedOrg(t, l, su, "ORG1")
+	key2 := createKeyedOrg(t, l, su, "ORG2")
+	request, err := sc.OpenSigningRequest(l.as(su), "ORG1", documentHash, []string{"ORG2"})
+	require.NoError(t, err)
+
+	_, err = sc.SubmitSignature(l.as(su), "ORG2", request.ID, signDocumentHash(t, key1))
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	_, err = sc.SubmitSignature(l.as(su), "ORG2", request.ID, signDocumentHash(t, key2))
+	require.NoError(t, err)
+	_, err = sc.ReadSignatureBundle(l.as(su), request.ID)
+	requireErrorCode(t, chaincode.ErrorCodeConflict, err)
+	_, err = sc.SubmitSignature(l.as(su), "ORG1", request.ID, signDocumentHash(t, key1))
+	require.NoError(t, err)
+
+	bundle, err := sc.ReadSignatureBundle(l.as(su), request.ID)
+	require.NoError(t, err)
+	require.Equal(t, "ORG1", bundle.IssuerOrgID)
+	require.Equal(t, []string{"ORG1", "ORG2"}, bundle.SignOrgIDs)
+	require.Len(t, bundle.Signatures, 2)
+	require.Equal(t, "ORG1", bundle.Signatures[0].OrgID)
+}
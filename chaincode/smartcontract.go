@@ -1,6 +1,8 @@
 package chaincode
 
 import (
+	"encoding/json"
+
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -13,7 +15,27 @@ func (s *SmartContract) GetName() string {
 	return "Organization"
 }
 
-func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-
-	return nil
+// InitLedger seeds the platform configuration if it is not on the ledger yet.
+// configJSON is the initial PlatformConfig, the defaults are used when it is
+// empty
+func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface, configJSON string) error {
+	config := defaultPlatformConfig()
+	if configJSON != "" {
+		config = &PlatformConfig{}
+		if err := json.Unmarshal([]byte(configJSON), config); err != nil {
+			return newValidationError("configJSON is not a PlatformConfig")
+		}
+	}
+	stateId, err := s.newPlatformConfigStateId(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	existingJSON, err := ctx.GetStub().GetState(stateId)
+	if err != nil {
+		return err
+	}
+	if existingJSON != nil {
+		return nil
+	}
+	return s.putPlatformConfig(ctx.GetStub(), config)
 }
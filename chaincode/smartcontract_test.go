@@ -1,8 +1,31 @@
 package chaincode_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/mocks"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 //go:generate counterfeiter -o mocks/transaction.go -fake-name TransactionContext . transactionContext
@@ -19,3 +42,170 @@ type chaincodeStub interface {
 type stateQueryIterator interface {
 	shim.StateQueryIteratorInterface
 }
+
+// ledger is an in-memory world state behind the fake stub. Every call to as
+// starts a new transaction one second after the previous one
+type ledger struct {
+	t     *testing.T
+	state map[string][]byte
+	stub  *mocks.ChaincodeStub
+	ctx   *mocks.TransactionContext
+	tx    int
+	now   time.Time
+}
+
+func newLedger(t *testing.T) *ledger {
+	l := &ledger{
+		t:     t,
+		state: map[string][]byte{},
+		stub:  &mocks.ChaincodeStub{},
+		ctx:   &mocks.TransactionContext{},
+		now:   time.Unix(1700000000, 0),
+	}
+	l.stub.GetStateStub = func(key string) ([]byte, error) {
+		return l.state[key], nil
+	}
+	l.stub.PutStateStub = func(key string, value []byte) error {
+		l.state[key] = value
+		return nil
+	}
+	l.stub.DelStateStub = func(key string) error {
+		delete(l.state, key)
+		return nil
+	}
+	l.stub.CreateCompositeKeyStub = shim.CreateCompositeKey
+	l.stub.SplitCompositeKeyStub = splitCompositeKey
+	l.stub.GetStateByPartialCompositeKeyStub = func(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, keys)
+		if err != nil {
+			return nil, err
+		}
+		return newIterator(l.keysWithPrefix(prefix, "", 0), l.state), nil
+	}
+	l.stub.GetStateByPartialCompositeKeyWithPaginationStub = func(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, keys)
+		if err != nil {
+			return nil, nil, err
+		}
+		page := l.keysWithPrefix(prefix, bookmark, int(pageSize)+1)
+		next := ""
+		if len(page) > int(pageSize) {
+			next = page[pageSize]
+			page = page[:pageSize]
+		}
+		metadata := &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(page)), Bookmark: next}
+		return newIterator(page, l.state), metadata, nil
+	}
+	l.ctx.GetStubReturns(l.stub)
+	return l
+}
+
+// as starts a transaction submitted by creator
+func (l *ledger) as(creator []byte) contractapi.TransactionContextInterface {
+	l.tx++
+	l.now = l.now.Add(time.Second)
+	l.stub.GetTxIDReturns(fmt.Sprintf("tx%d", l.tx))
+	l.stub.GetTxTimestampReturns(timestamppb.New(l.now), nil)
+	l.stub.GetCreatorReturns(creator, nil)
+	clientIdentity, err := cid.New(l.stub)
+	require.NoError(l.t, err)
+	l.ctx.GetClientIdentityReturns(clientIdentity)
+	return l.ctx
+}
+
+// call runs the before transaction hook of fn the way contractapi does
+func (l *ledger) call(creator []byte, fn string, args ...string) error {
+	ctx := l.as(creator)
+	l.stub.GetFunctionAndParametersReturns(fn, args)
+	hook := (&chaincode.SmartContract{}).GetBeforeTransaction().(func(contractapi.TransactionContextInterface) error)
+	return hook(ctx)
+}
+
+// keysWithPrefix returns the sorted keys starting with prefix from start on,
+// at most limit keys when limit is positive
+func (l *ledger) keysWithPrefix(prefix string, start string, limit int) []string {
+	keys := make([]string, 0)
+	for key := range l.state {
+		if strings.HasPrefix(key, prefix) && key >= start {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+func newIterator(keys []string, state map[string][]byte) *mocks.StateQueryIterator {
+	iterator := &mocks.StateQueryIterator{}
+	i := 0
+	iterator.HasNextStub = func() bool {
+		return i < len(keys)
+	}
+	iterator.NextStub = func() (*queryresult.KV, error) {
+		i++
+		return &queryresult.KV{Key: keys[i-1], Value: state[keys[i-1]]}, nil
+	}
+	return iterator
+}
+
+func splitCompositeKey(compositeKey string) (string, []string, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(compositeKey, "\x00"), "\x00"), "\x00")
+	return parts[0], parts[1:], nil
+}
+
+// newIdentity returns a serialized identity with a self signed certificate
+// carrying the fabric-ca attributes
+func newIdentity(t *testing.T, mspId string, commonName string, attrs map[string]string) []byte {
+	serialNumber, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: commonName},
+	}
+	if attrs != nil {
+		attrsJSON, err := json.Marshal(map[string]interface{}{"attrs": attrs})
+		require.NoError(t, err)
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: []int{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrsJSON})
+	}
+	return newCertificateIdentity(t, mspId, template)
+}
+
+// newCertificateIdentity returns a serialized identity with a certificate
+// self signed from template
+func newCertificateIdentity(t *testing.T, mspId string, template *x509.Certificate) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.NotBefore = time.Unix(1600000000, 0)
+	template.NotAfter = time.Unix(2000000000, 0)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	identity, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	require.NoError(t, err)
+	return identity
+}
+
+func newSuperAdmin(t *testing.T, commonName string) []byte {
+	return newIdentity(t, "DsolutionsOrgMSP", commonName, map[string]string{"diplom-mn.admin": "true"})
+}
+
+func newOrgAdmin(t *testing.T, orgId string) []byte {
+	return newIdentity(t, "Org1MSP", orgId+"-admin", map[string]string{"diplom.mn.org.id": orgId, "diplom.mn.org.role": "admin"})
+}
+
+// createOrg creates an active org with a zero credit
+func createOrg(t *testing.T, l *ledger, admin []byte, orgId string) {
+	sc := &chaincode.SmartContract{}
+	_, err := sc.CreateOrgWithInput(l.as(admin), chaincode.CreateOrgInput{OrgID: orgId, Name: orgId})
+	require.NoError(t, err)
+}
+
+func requireErrorCode(t *testing.T, code string, err error) {
+	t.Helper()
+	require.Error(t, err)
+	require.Equal(t, code, chaincode.ErrorCodeOf(err), err.Error())
+}
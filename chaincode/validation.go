@@ -0,0 +1,217 @@
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// sha256HexPattern is a sha256 in lowercase hex, the form hashes are keyed by
+var sha256HexPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var validate = newValidator()
+
+// FieldError describes one argument that failed a rule
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// newFieldsValidationError reports the failing fields in the error details
+func newFieldsValidationError(fields []FieldError) error {
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		rule := field.Rule
+		if field.Param != "" {
+			rule += "=" + field.Param
+		}
+		messages = append(messages, field.Field+": "+rule)
+	}
+	return &ContractError{
+		Code:    ErrorCodeValidation,
+		Message: fmt.Sprintf("Validation failed - %s", strings.Join(messages, ", ")),
+		Details: fields,
+	}
+}
+
+// Rules of the transaction arguments that are not covered by a payload type.
+// Field names are the argument names
+
+type orgInput struct {
+	OrgID           string `json:"orgId" validate:"required,id"`
+	Name            string `json:"name" validate:"required,max=256"`
+	Desc            string `json:"desc" validate:"max=2048"`
+	Email           string `json:"email" validate:"omitempty,email,max=256"`
+	InstitutionID   string `json:"institutionId" validate:"max=64"`
+	InstitutionName string `json:"institutionName" validate:"max=256"`
+	LogoUrl         string `json:"logo" validate:"omitempty,http_url,max=2048"`
+}
+
+type orgPatchInput struct {
+	OrgID   string `json:"orgId" validate:"required,id"`
+	Name    string `json:"name" validate:"max=256"`
+	Email   string `json:"email" validate:"omitempty,email,max=256"`
+	LogoUrl string `json:"logo" validate:"omitempty,http_url,max=2048"`
+}
+
+type createCreditInput struct {
+	OrgID  string `json:"orgId" validate:"required,id"`
+	Title  string `json:"title" validate:"max=256"`
+	Amount string `json:"amount" validate:"required,decimal=2"`
+}
+
+type orgMemberInput struct {
+	OrgID        string `json:"orgId" validate:"required,id"`
+	MSPID        string `json:"mspId" validate:"required,max=128"`
+	IdentityHash string `json:"identityHash" validate:"required,sha256hex"`
+	Role         string `json:"role" validate:"required,max=64"`
+}
+
+type orgRoleDelegationInput struct {
+	OrgID               string `json:"orgId" validate:"required,id"`
+	GranteeMSPID        string `json:"granteeMspId" validate:"required,max=128"`
+	GranteeIdentityHash string `json:"granteeIdentityHash" validate:"required,sha256hex"`
+	Role                string `json:"role" validate:"required,max=64"`
+	ExpiresAt           int64  `json:"expiresAt" validate:"gt=0"`
+}
+
+type orgTrustInput struct {
+	OrgID        string `json:"orgId" validate:"required,id"`
+	TrustedOrgID string `json:"trustedOrgId" validate:"required,id,nefield=OrgID"`
+	Purpose      string `json:"purpose" validate:"required,id"`
+	ValidFrom    int64  `json:"validFrom" validate:"gt=0"`
+	ValidTo      int64  `json:"validTo" validate:"gtfield=ValidFrom"`
+}
+
+type signingRequestInput struct {
+	IssuerOrgID     string   `json:"issuerOrgId" validate:"required,id"`
+	DocumentHash    string   `json:"documentHash" validate:"required,sha256hex"`
+	RequiredSigners []string `json:"requiredSigners" validate:"required,min=1,max=16,dive,required,id"`
+}
+
+type signatureInput struct {
+	RequestID string `json:"requestId" validate:"required,max=128"`
+	Signature string `json:"signature" validate:"required,base64,max=1024"`
+}
+
+type documentAnchorInput struct {
+	OrgID        string `json:"orgId" validate:"required,id"`
+	Hash         string `json:"hash" validate:"required,sha256hex"`
+	DocumentType string `json:"docType" validate:"required,id"`
+	Metadata     string `json:"metadata" validate:"omitempty,json,max=4096"`
+}
+
+type documentHashInput struct {
+	Hash string `json:"hash" validate:"required,sha256hex"`
+}
+
+type orgDocumentHashInput struct {
+	OrgID string `json:"orgId" validate:"required,id"`
+	Hash  string `json:"hash" validate:"required,sha256hex"`
+}
+
+// proposalStatusInput filters a list, an empty status lists every document
+type proposalStatusInput struct {
+	Status string `json:"status" validate:"omitempty,oneof=pending executed rejected expired"`
+}
+
+// orgApplicationStatusInput filters a list, an empty status lists every document
+type orgApplicationStatusInput struct {
+	Status string `json:"status" validate:"omitempty,oneof=pending approved rejected"`
+}
+
+type deniedIdentityInput struct {
+	Kind   string `json:"kind" validate:"required,oneof=id serial"`
+	Issuer string `json:"issuer" validate:"max=256"`
+	Value  string `json:"value" validate:"required,max=1024"`
+	Reason string `json:"reason" validate:"max=1024"`
+}
+
+type referenceInput struct {
+	ID     string `json:"id" validate:"required,max=128"`
+	Reason string `json:"reason" validate:"max=1024"`
+}
+
+type pageInput struct {
+	PageSize int32  `json:"pageSize" validate:"min=1,max=1000"`
+	BookMark string `json:"bookMark" validate:"max=1024"`
+}
+
+type sortedPageInput struct {
+	SortArg  string `json:"sortArg" validate:"required,oneof=asc desc"`
+	PageSize int32  `json:"pageSize" validate:"min=1,max=1000"`
+	BookMark string `json:"bookMark" validate:"max=1024"`
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterValidation("id", isIdentifier)
+	v.RegisterValidation("decimal", isDecimal)
+	v.RegisterValidation("positive", isPositiveDecimal)
+	v.RegisterValidation("sha256hex", isSha256Hex)
+	return v
+}
+
+// validateInput checks a struct against its validate tags and reports every
+// failing field
+func validateInput(input interface{}) error {
+	err := validate.Struct(input)
+	if err == nil {
+		return nil
+	}
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return newValidationError("%s", err)
+	}
+	fields := make([]FieldError, 0, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		fields = append(fields, FieldError{
+			Field: fieldError.Field(),
+			Rule:  fieldError.Tag(),
+			Param: fieldError.Param(),
+		})
+	}
+	return newFieldsValidationError(fields)
+}
+
+func isIdentifier(fl validator.FieldLevel) bool {
+	return identifierPattern.MatchString(fl.Field().String())
+}
+
+func isSha256Hex(fl validator.FieldLevel) bool {
+	return sha256HexPattern.MatchString(fl.Field().String())
+}
+
+// isDecimal accepts non negative decimal strings with at most param decimal places
+func isDecimal(fl validator.FieldLevel) bool {
+	value, err := decimal.NewFromString(fl.Field().String())
+	if err != nil || value.IsNegative() {
+		return false
+	}
+	maxScale, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		return false
+	}
+	return -value.Exponent() <= int32(maxScale)
+}
+
+func isPositiveDecimal(fl validator.FieldLevel) bool {
+	value, err := decimal.NewFromString(fl.Field().String())
+	return err == nil && value.IsPositive()
+}
@@ -0,0 +1,32 @@
+package chaincode_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashesAreStrictSha256Hex(t *testing.T) {
+	l := newLedger(t)
+	sc := &chaincode.SmartContract{}
+	su := newSuperAdmin(t, "su")
+	createOrg(t, l, su, "ORG1")
+	hash := strings.Repeat("ab", 32)
+
+	for _, invalid := range []string{"0x" + hash[2:], hash[2:], hash + "00", strings.Repeat("zz", 32)} {
+		_, err := sc.LookupDocumentHash(l.as(su), invalid)
+		requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+		_, err = sc.GrantOrgRole(l.as(su), "ORG1", "Org1MSP", invalid, chaincode.RoleOrgMember)
+		requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	}
+
+	// document hashes are lowercased before they are validated
+	_, err := sc.LookupDocumentHash(l.as(su), strings.ToUpper(hash))
+	requireErrorCode(t, chaincode.ErrorCodeNotFound, err)
+	_, err = sc.GrantOrgRole(l.as(su), "ORG1", "Org1MSP", strings.ToUpper(hash), chaincode.RoleOrgMember)
+	requireErrorCode(t, chaincode.ErrorCodeValidation, err)
+	_, err = sc.GrantOrgRole(l.as(su), "ORG1", "Org1MSP", hash, chaincode.RoleOrgMember)
+	require.NoError(t, err)
+}
@@ -6,6 +6,7 @@ require github.com/hyperledger/fabric-contract-api-go v1.2.1
 
 require (
 	github.com/go-playground/validator/v10 v10.16.0
+	github.com/golang/protobuf v1.5.2
 	github.com/google/uuid v1.4.0
 	github.com/hyperledger/fabric-chaincode-go v0.0.0-20230228194215-b84622ba6a7a
 	github.com/hyperledger/fabric-protos-go v0.3.0
@@ -27,7 +28,6 @@ require (
 	github.com/gobuffalo/envy v1.10.1 // indirect
 	github.com/gobuffalo/packd v1.0.1 // indirect
 	github.com/gobuffalo/packr v1.30.1 // indirect
-	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/joho/godotenv v1.4.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/leodido/go-urn v1.2.4 // indirect
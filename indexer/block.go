@@ -0,0 +1,92 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// blockEnvelopes extracts the contract event envelopes of the valid
+// transactions of a block in block order
+func blockEnvelopes(block *common.Block, chaincodeName string) ([]*events.Envelope, error) {
+	var txFilter []byte
+	if block.Metadata != nil && len(block.Metadata.Metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		txFilter = block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+	envelopes := make([]*events.Envelope, 0)
+	for i, envelopeBytes := range block.Data.Data {
+		if i < len(txFilter) && peer.TxValidationCode(txFilter[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+		chaincodeEvent, err := transactionChaincodeEvent(envelopeBytes)
+		if err != nil {
+			return nil, fmt.Errorf("Block %d tx %d - %s", block.Header.Number, i, err)
+		}
+		if chaincodeEvent == nil || chaincodeEvent.ChaincodeId != chaincodeName || chaincodeEvent.EventName != events.Name {
+			continue
+		}
+		var envelope events.Envelope
+		if err = json.Unmarshal(chaincodeEvent.Payload, &envelope); err != nil {
+			return nil, fmt.Errorf("Block %d tx %d - %s", block.Header.Number, i, err)
+		}
+		envelopes = append(envelopes, &envelope)
+	}
+	return envelopes, nil
+}
+
+// transactionChaincodeEvent returns the chaincode event of an endorser
+// transaction, nil for other transaction types or transactions without event
+func transactionChaincodeEvent(envelopeBytes []byte) (*peer.ChaincodeEvent, error) {
+	envelope := &common.Envelope{}
+	if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+		return nil, err
+	}
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return nil, err
+	}
+	if payload.Header == nil {
+		return nil, nil
+	}
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, channelHeader); err != nil {
+		return nil, err
+	}
+	if common.HeaderType(channelHeader.Type) != common.HeaderType_ENDORSER_TRANSACTION {
+		return nil, nil
+	}
+	transaction := &peer.Transaction{}
+	if err := proto.Unmarshal(payload.Data, transaction); err != nil {
+		return nil, err
+	}
+	for _, action := range transaction.Actions {
+		actionPayload := &peer.ChaincodeActionPayload{}
+		if err := proto.Unmarshal(action.Payload, actionPayload); err != nil {
+			return nil, err
+		}
+		if actionPayload.Action == nil {
+			continue
+		}
+		responsePayload := &peer.ProposalResponsePayload{}
+		if err := proto.Unmarshal(actionPayload.Action.ProposalResponsePayload, responsePayload); err != nil {
+			return nil, err
+		}
+		chaincodeAction := &peer.ChaincodeAction{}
+		if err := proto.Unmarshal(responsePayload.Extension, chaincodeAction); err != nil {
+			return nil, err
+		}
+		if len(chaincodeAction.Events) == 0 {
+			continue
+		}
+		chaincodeEvent := &peer.ChaincodeEvent{}
+		if err := proto.Unmarshal(chaincodeAction.Events, chaincodeEvent); err != nil {
+			return nil, err
+		}
+		return chaincodeEvent, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,35 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/diplom-mn/chaincode-go-organization/indexer"
+)
+
+func main() {
+	blocksDir := flag.String("blocks", "./blocks", "directory with <number>.block files to replay")
+	dbPath := flag.String("db", "./organization.db", "SQLite database path")
+	chaincodeName := flag.String("chaincode", "organization", "chaincode name the contract is deployed as")
+	flag.Parse()
+
+	source, err := indexer.NewFileBlockSource(*blocksDir)
+	if err != nil {
+		log.Panicf("Error opening block source: %v", err)
+	}
+	idx, err := indexer.Open(*dbPath, source, *chaincodeName)
+	if err != nil {
+		log.Panicf("Error opening database: %v", err)
+	}
+	defer idx.Close()
+
+	count, err := idx.Run()
+	if err != nil {
+		log.Panicf("Error indexing blocks: %v", err)
+	}
+	log.Printf("Indexed %d blocks", count)
+}
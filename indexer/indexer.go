@@ -0,0 +1,103 @@
+// Package indexer projects the Organization contract events into SQLite
+// tables so that reports can be run without querying the peer. Blocks are
+// applied one SQL transaction at a time together with the checkpoint, so an
+// interrupted run resumes after the last fully indexed block.
+package indexer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	_ "modernc.org/sqlite"
+)
+
+type Indexer struct {
+	db            *sql.DB
+	source        BlockSource
+	chaincodeName string
+}
+
+// Open opens or creates the SQLite database at path and migrates its schema
+func Open(path string, source BlockSource, chaincodeName string) (*Indexer, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Indexer{db: db, source: source, chaincodeName: chaincodeName}, nil
+}
+
+func (i *Indexer) Close() error {
+	return i.db.Close()
+}
+
+// Run indexes the blocks after the checkpoint until the source is exhausted and
+// returns the number of indexed blocks. A missing block stops the run with an
+// error and the checkpoint stays at the last block before the gap
+func (i *Indexer) Run() (int, error) {
+	last, ok, err := readCheckpoint(i.db)
+	if err != nil {
+		return 0, err
+	}
+	next := uint64(0)
+	if ok {
+		next = last + 1
+	}
+	if err = i.source.Seek(next); err != nil {
+		return 0, err
+	}
+	count := 0
+	for {
+		block, err := i.source.Next()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		if block.Header == nil {
+			return count, fmt.Errorf("Block %d has no header", next)
+		}
+		if block.Header.Number != next {
+			return count, fmt.Errorf("Expected block %d, got block %d", next, block.Header.Number)
+		}
+		if err = i.indexBlock(next, block); err != nil {
+			return count, err
+		}
+		next++
+		count++
+	}
+}
+
+func (i *Indexer) indexBlock(number uint64, block *common.Block) error {
+	envelopes, err := blockEnvelopes(block, i.chaincodeName)
+	if err != nil {
+		return err
+	}
+	tx, err := i.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, envelope := range envelopes {
+		if err = applyEnvelope(tx, envelope); err != nil {
+			return err
+		}
+	}
+	if err = writeCheckpoint(tx, number, time.Now().Unix()); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Indexed block %d with %d contract transactions", number, len(envelopes))
+	return nil
+}
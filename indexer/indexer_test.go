@@ -0,0 +1,71 @@
+package indexer
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceBlockSource delivers the given blocks regardless of their numbers
+type sliceBlockSource struct {
+	blocks []*common.Block
+	cursor int
+}
+
+func (s *sliceBlockSource) Seek(blockNumber uint64) error {
+	for s.cursor < len(s.blocks) && s.blocks[s.cursor].Header.Number < blockNumber {
+		s.cursor++
+	}
+	return nil
+}
+
+func (s *sliceBlockSource) Next() (*common.Block, error) {
+	if s.cursor >= len(s.blocks) {
+		return nil, io.EOF
+	}
+	s.cursor++
+	return s.blocks[s.cursor-1], nil
+}
+
+func emptyBlocks(numbers ...uint64) []*common.Block {
+	blocks := make([]*common.Block, 0, len(numbers))
+	for _, number := range numbers {
+		blocks = append(blocks, &common.Block{Header: &common.BlockHeader{Number: number}, Data: &common.BlockData{}})
+	}
+	return blocks
+}
+
+func TestRunStopsAtGap(t *testing.T) {
+	idx, err := Open(filepath.Join(t.TempDir(), "organization.db"), &sliceBlockSource{blocks: emptyBlocks(0, 1, 3, 4)}, "organization")
+	require.NoError(t, err)
+	defer idx.Close()
+
+	count, err := idx.Run()
+	require.EqualError(t, err, "Expected block 2, got block 3")
+	require.Equal(t, 2, count)
+	last, ok, err := readCheckpoint(idx.db)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), last)
+
+	idx.source = &sliceBlockSource{blocks: emptyBlocks(0, 1, 2, 3, 4)}
+	count, err = idx.Run()
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	last, _, err = readCheckpoint(idx.db)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), last)
+}
+
+func TestRunRejectsReplayedBlock(t *testing.T) {
+	idx, err := Open(filepath.Join(t.TempDir(), "organization.db"), &sliceBlockSource{blocks: emptyBlocks(0, 0)}, "organization")
+	require.NoError(t, err)
+	defer idx.Close()
+
+	count, err := idx.Run()
+	require.EqualError(t, err, "Expected block 1, got block 0")
+	require.Equal(t, 1, count)
+}
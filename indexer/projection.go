@@ -0,0 +1,202 @@
+package indexer
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS orgs (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		email TEXT NOT NULL,
+		institution_id TEXT NOT NULL,
+		institution_name TEXT NOT NULL,
+		description TEXT NOT NULL,
+		org_credit_id TEXT NOT NULL,
+		logo_url TEXT NOT NULL,
+		is_active INTEGER NOT NULL,
+		pub_key_type TEXT NOT NULL,
+		pub_key_pem TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		updated_tx_id TEXT NOT NULL,
+		archived_at INTEGER,
+		parent_org_id TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS orgs_institution_id ON orgs (institution_id)`,
+	`CREATE TABLE IF NOT EXISTS org_keys (
+		org_id TEXT NOT NULL,
+		pub_key_type TEXT NOT NULL,
+		pub_key_pem TEXT NOT NULL,
+		set_tx_id TEXT NOT NULL PRIMARY KEY,
+		set_at INTEGER NOT NULL,
+		removed_tx_id TEXT,
+		removed_at INTEGER
+	)`,
+	`CREATE INDEX IF NOT EXISTS org_keys_org_id ON org_keys (org_id)`,
+	`CREATE TABLE IF NOT EXISTS credits (
+		credit_id TEXT NOT NULL,
+		org_id TEXT NOT NULL,
+		balance TEXT NOT NULL,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (credit_id, org_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS credit_logs (
+		log_id TEXT PRIMARY KEY,
+		credit_id TEXT NOT NULL,
+		org_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		title TEXT NOT NULL,
+		credit TEXT NOT NULL,
+		debit TEXT NOT NULL,
+		balance TEXT NOT NULL,
+		tx_id TEXT NOT NULL,
+		tx_timestamp INTEGER NOT NULL,
+		actor_msp_id TEXT NOT NULL,
+		actor_id TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS credit_logs_org_ts ON credit_logs (org_id, credit_id, tx_timestamp)`,
+	`CREATE TABLE IF NOT EXISTS checkpoint (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		block_number INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`,
+}
+
+var creditLogTypes = map[string]string{
+	events.CreditCreated: "mint",
+	events.CreditMinted:  "mint",
+	events.CreditBurned:  "burn",
+	events.CreditSpent:   "spend",
+}
+
+func migrate(db *sql.DB) error {
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("Failed to migrate schema - %s", err)
+		}
+	}
+	return nil
+}
+
+// readCheckpoint returns the last indexed block number, ok is false when no
+// block has been indexed yet
+func readCheckpoint(db *sql.DB) (blockNumber uint64, ok bool, err error) {
+	err = db.QueryRow(`SELECT block_number FROM checkpoint WHERE id = 1`).Scan(&blockNumber)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return blockNumber, true, nil
+}
+
+func writeCheckpoint(tx *sql.Tx, blockNumber uint64, ts int64) error {
+	_, err := tx.Exec(`INSERT INTO checkpoint (id, block_number, updated_at) VALUES (1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET block_number = excluded.block_number, updated_at = excluded.updated_at`,
+		blockNumber, ts)
+	return err
+}
+
+// applyEnvelope projects the events of one transaction
+func applyEnvelope(tx *sql.Tx, envelope *events.Envelope) error {
+	for _, event := range envelope.Events {
+		var err error
+		switch event.Type {
+		case events.OrgCreated, events.OrgUpdated, events.OrgKeySet, events.OrgKeyRemoved, events.OrgArchived:
+			err = applyOrgEvent(tx, envelope, event)
+		case events.OrgPurged:
+			err = applyOrgPurgedEvent(tx, event)
+		case events.CreditCreated, events.CreditMinted, events.CreditBurned, events.CreditSpent:
+			err = applyCreditEvent(tx, envelope, event)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to apply %s of tx %s - %s", event.Type, envelope.TxID, err)
+		}
+	}
+	return nil
+}
+
+func applyOrgEvent(tx *sql.Tx, envelope *events.Envelope, event events.Event) error {
+	var org events.Org
+	if err := event.Decode(&org); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO orgs (id, name, email, institution_id, institution_name, description,
+			org_credit_id, logo_url, is_active, pub_key_type, pub_key_pem, created_at, updated_at, updated_tx_id,
+			archived_at, parent_org_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, email = excluded.email,
+			institution_id = excluded.institution_id, institution_name = excluded.institution_name,
+			description = excluded.description, org_credit_id = excluded.org_credit_id,
+			logo_url = excluded.logo_url, is_active = excluded.is_active,
+			pub_key_type = excluded.pub_key_type, pub_key_pem = excluded.pub_key_pem,
+			updated_at = excluded.updated_at, updated_tx_id = excluded.updated_tx_id,
+			archived_at = excluded.archived_at, parent_org_id = excluded.parent_org_id`,
+		org.ID, org.Name, org.Email, org.InstitutionID, org.InstitutionName, org.Desc,
+		org.OrgCreditID, org.LogoUrl, org.IsActive, org.PubKeyType, org.PubKeyPem,
+		envelope.TxTimestamp, envelope.TxTimestamp, envelope.TxID, nullableTimestamp(org.ArchivedAt), org.ParentOrgID)
+	if err != nil {
+		return err
+	}
+	switch event.Type {
+	case events.OrgKeySet:
+		_, err = tx.Exec(`INSERT INTO org_keys (org_id, pub_key_type, pub_key_pem, set_tx_id, set_at)
+			VALUES (?, ?, ?, ?, ?) ON CONFLICT (set_tx_id) DO NOTHING`,
+			org.ID, org.PubKeyType, org.PubKeyPem, envelope.TxID, envelope.TxTimestamp)
+	case events.OrgKeyRemoved:
+		_, err = tx.Exec(`UPDATE org_keys SET removed_tx_id = ?, removed_at = ?
+			WHERE org_id = ? AND removed_tx_id IS NULL`,
+			envelope.TxID, envelope.TxTimestamp, org.ID)
+	}
+	return err
+}
+
+// applyOrgPurgedEvent removes every row of a purged org
+func applyOrgPurgedEvent(tx *sql.Tx, event events.Event) error {
+	var org events.Org
+	if err := event.Decode(&org); err != nil {
+		return err
+	}
+	for _, statement := range []string{
+		`DELETE FROM credit_logs WHERE org_id = ?`,
+		`DELETE FROM credits WHERE org_id = ?`,
+		`DELETE FROM org_keys WHERE org_id = ?`,
+		`DELETE FROM orgs WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(statement, org.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nullableTimestamp(ts int64) interface{} {
+	if ts == 0 {
+		return nil
+	}
+	return ts
+}
+
+func applyCreditEvent(tx *sql.Tx, envelope *events.Envelope, event events.Event) error {
+	var credit events.Credit
+	if err := event.Decode(&credit); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO credit_logs (log_id, credit_id, org_id, type, title, credit, debit,
+			balance, tx_id, tx_timestamp, actor_msp_id, actor_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (log_id) DO NOTHING`,
+		credit.LogID, credit.ID, credit.OrgID, creditLogTypes[event.Type], credit.Title, credit.Credit,
+		credit.Debit, credit.Balance, envelope.TxID, envelope.TxTimestamp, envelope.Actor.MSPID, envelope.Actor.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO credits (credit_id, org_id, balance, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (credit_id, org_id) DO UPDATE SET balance = excluded.balance, updated_at = excluded.updated_at`,
+		credit.ID, credit.OrgID, credit.Balance, envelope.TxTimestamp)
+	return err
+}
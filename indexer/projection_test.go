@@ -0,0 +1,301 @@
+package indexer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/diplom-mn/chaincode-go-organization/chaincode/events"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	// every connection would open its own in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, migrate(db))
+	return db
+}
+
+func newEvent(t *testing.T, eventType string, data interface{}) events.Event {
+	dataJSON, err := json.Marshal(data)
+	require.NoError(t, err)
+	return events.Event{Type: eventType, Version: events.Version, Data: dataJSON}
+}
+
+func newEnvelope(txId string, ts int64, evts ...events.Event) *events.Envelope {
+	return &events.Envelope{
+		Version:     events.Version,
+		TxID:        txId,
+		TxTimestamp: ts,
+		Actor:       events.Actor{MSPID: "Org1MSP", ID: "admin"},
+		Events:      evts,
+	}
+}
+
+// queryRows returns every row of query as its columns joined with |
+func queryRows(t *testing.T, db *sql.DB, query string) []string {
+	rows, err := db.Query(query)
+	require.NoError(t, err)
+	defer rows.Close()
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	result := make([]string, 0)
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		require.NoError(t, rows.Scan(pointers...))
+		row := ""
+		for i, value := range values {
+			if i > 0 {
+				row += "|"
+			}
+			row += value.String
+		}
+		result = append(result, row)
+	}
+	require.NoError(t, rows.Err())
+	return result
+}
+
+func TestApplyEnvelope(t *testing.T) {
+	org := events.Org{ID: "ORG1", Name: "Org 1", InstitutionID: "I1", OrgCreditID: "C1", IsActive: true}
+	renamed := org
+	renamed.Name = "Org One"
+	keyed := org
+	keyed.PubKeyType, keyed.PubKeyPem = "ecdsa:P-384", "PEM1"
+	rekeyed := org
+	rekeyed.PubKeyType, rekeyed.PubKeyPem = "ecdsa:P-384", "PEM2"
+	archived := org
+	archived.IsActive, archived.ArchivedAt = false, 30
+	child := events.Org{ID: "ORG2", Name: "Org 2", OrgCreditID: "C2", IsActive: true, ParentOrgID: "ORG1"}
+	credit := func(logId string, orgId string, creditAmount string, debit string, balance string) events.Credit {
+		return events.Credit{ID: "C" + orgId[3:], OrgID: orgId, LogID: logId, Title: logId, Credit: creditAmount, Debit: debit, Balance: balance}
+	}
+
+	tests := []struct {
+		name      string
+		envelopes func(t *testing.T) []*events.Envelope
+		query     string
+		rows      []string
+	}{
+		{
+			name: "org created",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{newEnvelope("tx1", 10, newEvent(t, events.OrgCreated, org), newEvent(t, events.OrgCreated, child))}
+			},
+			query: `SELECT id, name, institution_id, is_active, created_at, updated_tx_id, archived_at, parent_org_id FROM orgs ORDER BY id`,
+			rows:  []string{"ORG1|Org 1|I1|1|10|tx1||", "ORG2|Org 2||1|10|tx1||ORG1"},
+		},
+		{
+			name: "org updated keeps its creation time",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{
+					newEnvelope("tx1", 10, newEvent(t, events.OrgCreated, org)),
+					newEnvelope("tx2", 20, newEvent(t, events.OrgUpdated, renamed)),
+				}
+			},
+			query: `SELECT id, name, created_at, updated_at, updated_tx_id FROM orgs`,
+			rows:  []string{"ORG1|Org One|10|20|tx2"},
+		},
+		{
+			name: "org archived",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{
+					newEnvelope("tx1", 10, newEvent(t, events.OrgCreated, org)),
+					newEnvelope("tx2", 30, newEvent(t, events.OrgArchived, archived)),
+				}
+			},
+			query: `SELECT id, is_active, archived_at FROM orgs`,
+			rows:  []string{"ORG1|0|30"},
+		},
+		{
+			name: "org keys set, removed and set again",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{
+					newEnvelope("tx1", 10, newEvent(t, events.OrgCreated, org)),
+					newEnvelope("tx2", 20, newEvent(t, events.OrgKeySet, keyed)),
+					newEnvelope("tx3", 30, newEvent(t, events.OrgKeyRemoved, org)),
+					newEnvelope("tx4", 40, newEvent(t, events.OrgKeySet, rekeyed)),
+				}
+			},
+			query: `SELECT org_id, pub_key_pem, set_tx_id, set_at, removed_tx_id, removed_at FROM org_keys ORDER BY set_at`,
+			rows:  []string{"ORG1|PEM1|tx2|20|tx3|30", "ORG1|PEM2|tx4|40||"},
+		},
+		{
+			name: "org key removed clears the org key",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{
+					newEnvelope("tx1", 10, newEvent(t, events.OrgKeySet, keyed)),
+					newEnvelope("tx2", 20, newEvent(t, events.OrgKeyRemoved, org)),
+				}
+			},
+			query: `SELECT id, pub_key_type, pub_key_pem FROM orgs`,
+			rows:  []string{"ORG1||"},
+		},
+		{
+			name: "credit logs map their event types",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{
+					newEnvelope("tx1", 10, newEvent(t, events.CreditCreated, credit("tx1", "ORG1", "0", "0", "0"))),
+					newEnvelope("tx2", 20, newEvent(t, events.CreditMinted, credit("tx2", "ORG1", "0", "10", "10"))),
+					newEnvelope("tx3", 30, newEvent(t, events.CreditBurned, credit("tx3", "ORG1", "4", "0", "6"))),
+					newEnvelope("tx4", 40, newEvent(t, events.CreditSpent, credit("tx4", "ORG1", "1", "0", "5"))),
+				}
+			},
+			query: `SELECT log_id, credit_id, org_id, type, credit, debit, balance, tx_timestamp, actor_msp_id, actor_id FROM credit_logs ORDER BY tx_timestamp`,
+			rows: []string{
+				"tx1|C1|ORG1|mint|0|0|0|10|Org1MSP|admin",
+				"tx2|C1|ORG1|mint|0|10|10|20|Org1MSP|admin",
+				"tx3|C1|ORG1|burn|4|0|6|30|Org1MSP|admin",
+				"tx4|C1|ORG1|spend|1|0|5|40|Org1MSP|admin",
+			},
+		},
+		{
+			name: "credit balance follows the last log",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{
+					newEnvelope("tx1", 10, newEvent(t, events.CreditMinted, credit("tx1", "ORG1", "0", "10", "10"))),
+					newEnvelope("tx2", 20, newEvent(t, events.CreditSpent, credit("tx2", "ORG1", "1", "0", "9"))),
+					newEnvelope("tx3", 30, newEvent(t, events.CreditMinted, credit("tx3", "ORG2", "0", "3", "3"))),
+				}
+			},
+			query: `SELECT credit_id, org_id, balance, updated_at FROM credits ORDER BY org_id`,
+			rows:  []string{"C1|ORG1|9|20", "C2|ORG2|3|30"},
+		},
+		{
+			name: "replayed credit log is kept once",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				envelope := newEnvelope("tx1", 10, newEvent(t, events.CreditMinted, credit("tx1", "ORG1", "0", "10", "10")))
+				return []*events.Envelope{envelope, envelope}
+			},
+			query: `SELECT log_id, balance FROM credit_logs`,
+			rows:  []string{"tx1|10"},
+		},
+		{
+			name: "org purged deletes every row of the org",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{
+					newEnvelope("tx1", 10, newEvent(t, events.CreditCreated, credit("tx1", "ORG1", "0", "0", "0")), newEvent(t, events.OrgCreated, org)),
+					newEnvelope("tx2", 10, newEvent(t, events.CreditCreated, credit("tx2", "ORG2", "0", "0", "0")), newEvent(t, events.OrgCreated, child)),
+					newEnvelope("tx3", 20, newEvent(t, events.OrgKeySet, keyed)),
+					newEnvelope("tx4", 30, newEvent(t, events.OrgKeyRemoved, org)),
+					newEnvelope("tx5", 40, newEvent(t, events.OrgPurged, org)),
+				}
+			},
+			query: `SELECT 'org', id FROM orgs UNION ALL SELECT 'key', org_id FROM org_keys
+				UNION ALL SELECT 'credit', org_id FROM credits UNION ALL SELECT 'log', org_id FROM credit_logs`,
+			rows: []string{"org|ORG2", "credit|ORG2", "log|ORG2"},
+		},
+		{
+			name: "other events are ignored",
+			envelopes: func(t *testing.T) []*events.Envelope {
+				return []*events.Envelope{newEnvelope("tx1", 10, newEvent(t, events.ProposalCreated, events.Proposal{ID: "tx1"}))}
+			},
+			query: `SELECT id FROM orgs UNION ALL SELECT log_id FROM credit_logs`,
+			rows:  []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := openTestDB(t)
+			tx, err := db.Begin()
+			require.NoError(t, err)
+			for _, envelope := range test.envelopes(t) {
+				require.NoError(t, applyEnvelope(tx, envelope))
+			}
+			require.NoError(t, tx.Commit())
+			require.Equal(t, test.rows, queryRows(t, db, test.query))
+		})
+	}
+}
+
+func TestApplyEnvelopeRejectsMalformedData(t *testing.T) {
+	db := openTestDB(t)
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	envelope := newEnvelope("tx1", 10, events.Event{Type: events.OrgCreated, Version: events.Version, Data: json.RawMessage(`"ORG1"`)})
+	require.ErrorContains(t, applyEnvelope(tx, envelope), "Failed to apply OrgCreated of tx tx1")
+}
+
+func marshalProto(t *testing.T, message proto.Message) []byte {
+	messageBytes, err := proto.Marshal(message)
+	require.NoError(t, err)
+	return messageBytes
+}
+
+// newTransaction wraps a contract event envelope into an endorser transaction
+func newTransaction(t *testing.T, chaincodeName string, envelope *events.Envelope) []byte {
+	payload, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	chaincodeEvent := marshalProto(t, &peer.ChaincodeEvent{ChaincodeId: chaincodeName, TxId: envelope.TxID, EventName: events.Name, Payload: payload})
+	responsePayload := marshalProto(t, &peer.ProposalResponsePayload{Extension: marshalProto(t, &peer.ChaincodeAction{Events: chaincodeEvent})})
+	actionPayload := marshalProto(t, &peer.ChaincodeActionPayload{Action: &peer.ChaincodeEndorsedAction{ProposalResponsePayload: responsePayload}})
+	transaction := marshalProto(t, &peer.Transaction{Actions: []*peer.TransactionAction{{Payload: actionPayload}}})
+	channelHeader := marshalProto(t, &common.ChannelHeader{Type: int32(common.HeaderType_ENDORSER_TRANSACTION), TxId: envelope.TxID})
+	return marshalProto(t, &common.Envelope{Payload: marshalProto(t, &common.Payload{Header: &common.Header{ChannelHeader: channelHeader}, Data: transaction})})
+}
+
+// newBlock builds a block of transactions with their validation codes
+func newBlock(number uint64, transactions [][]byte, validationCodes ...peer.TxValidationCode) *common.Block {
+	txFilter := make([]byte, len(validationCodes))
+	for i, code := range validationCodes {
+		txFilter[i] = byte(code)
+	}
+	metadata := make([][]byte, len(common.BlockMetadataIndex_name))
+	metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = txFilter
+	return &common.Block{Header: &common.BlockHeader{Number: number}, Data: &common.BlockData{Data: transactions}, Metadata: &common.BlockMetadata{Metadata: metadata}}
+}
+
+func TestRunIndexesBlocks(t *testing.T) {
+	org := events.Org{ID: "ORG1", Name: "Org 1", OrgCreditID: "C1", IsActive: true}
+	blocks := []*common.Block{
+		newBlock(0, [][]byte{
+			newTransaction(t, "organization", newEnvelope("tx1", 10, newEvent(t, events.OrgCreated, org))),
+			newTransaction(t, "other", newEnvelope("tx2", 10, newEvent(t, events.OrgCreated, events.Org{ID: "OTHER"}))),
+			newTransaction(t, "organization", newEnvelope("tx3", 10, newEvent(t, events.OrgCreated, events.Org{ID: "INVALID"}))),
+		}, peer.TxValidationCode_VALID, peer.TxValidationCode_VALID, peer.TxValidationCode_MVCC_READ_CONFLICT),
+	}
+	idx, err := Open(filepath.Join(t.TempDir(), "organization.db"), &sliceBlockSource{blocks: blocks}, "organization")
+	require.NoError(t, err)
+	defer idx.Close()
+
+	count, err := idx.Run()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, []string{"ORG1"}, queryRows(t, idx.db, `SELECT id FROM orgs`))
+	require.Equal(t, []string{"0"}, queryRows(t, idx.db, `SELECT block_number FROM checkpoint`))
+}
+
+func TestRunRollsBackFailedBlock(t *testing.T) {
+	org := events.Org{ID: "ORG1", Name: "Org 1", OrgCreditID: "C1", IsActive: true}
+	malformed := newEnvelope("tx3", 20, events.Event{Type: events.OrgUpdated, Version: events.Version, Data: json.RawMessage(`[]`)})
+	blocks := []*common.Block{
+		newBlock(0, [][]byte{newTransaction(t, "organization", newEnvelope("tx1", 10, newEvent(t, events.OrgCreated, org)))}, peer.TxValidationCode_VALID),
+		newBlock(1, [][]byte{
+			newTransaction(t, "organization", newEnvelope("tx2", 20, newEvent(t, events.OrgCreated, events.Org{ID: "ORG2"}))),
+			newTransaction(t, "organization", malformed),
+		}, peer.TxValidationCode_VALID, peer.TxValidationCode_VALID),
+	}
+	idx, err := Open(filepath.Join(t.TempDir(), "organization.db"), &sliceBlockSource{blocks: blocks}, "organization")
+	require.NoError(t, err)
+	defer idx.Close()
+
+	count, err := idx.Run()
+	require.ErrorContains(t, err, fmt.Sprintf("Failed to apply %s of tx tx3", events.OrgUpdated))
+	require.Equal(t, 1, count)
+	// the rows of the failed block and its checkpoint are rolled back together
+	require.Equal(t, []string{"ORG1"}, queryRows(t, idx.db, `SELECT id FROM orgs`))
+	require.Equal(t, []string{"0"}, queryRows(t, idx.db, `SELECT block_number FROM checkpoint`))
+}
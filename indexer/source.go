@@ -0,0 +1,86 @@
+package indexer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+)
+
+// BlockSource delivers blocks in order. Next returns io.EOF once no more blocks
+// are available
+type BlockSource interface {
+	Seek(blockNumber uint64) error
+	Next() (*common.Block, error)
+}
+
+// FileBlockSource replays blocks stored as files named <number>.block, e.g. the
+// output of `peer channel fetch <number>`. It stands in for a peer when running
+// the indexer locally
+type FileBlockSource struct {
+	dir    string
+	files  []blockFile
+	cursor int
+}
+
+type blockFile struct {
+	number uint64
+	path   string
+}
+
+func NewFileBlockSource(dir string) (*FileBlockSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]blockFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".block") {
+			continue
+		}
+		number, err := strconv.ParseUint(strings.TrimSuffix(name, ".block"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid block file name %s", name)
+		}
+		files = append(files, blockFile{number: number, path: filepath.Join(dir, name)})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].number < files[j].number
+	})
+	return &FileBlockSource{dir: dir, files: files}, nil
+}
+
+// Seek positions the source at the first block with a number >= blockNumber
+func (s *FileBlockSource) Seek(blockNumber uint64) error {
+	s.cursor = sort.Search(len(s.files), func(i int) bool {
+		return s.files[i].number >= blockNumber
+	})
+	return nil
+}
+
+func (s *FileBlockSource) Next() (*common.Block, error) {
+	if s.cursor >= len(s.files) {
+		return nil, io.EOF
+	}
+	file := s.files[s.cursor]
+	blockBytes, err := os.ReadFile(file.path)
+	if err != nil {
+		return nil, err
+	}
+	block := &common.Block{}
+	if err = proto.Unmarshal(blockBytes, block); err != nil {
+		return nil, fmt.Errorf("Failed to parse block file %s - %s", file.path, err)
+	}
+	if block.Header == nil || block.Header.Number != file.number {
+		return nil, fmt.Errorf("Block file %s does not contain block %d", file.path, file.number)
+	}
+	s.cursor++
+	return block, nil
+}
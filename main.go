@@ -8,12 +8,10 @@ import (
 	"log"
 
 	"github.com/diplom-mn/chaincode-go-organization/chaincode"
-
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
 func main() {
-	chaincode, err := contractapi.NewChaincode(&chaincode.SmartContract{})
+	chaincode, err := chaincode.NewChaincode(&chaincode.SmartContract{})
 	if err != nil {
 		log.Panicf("Error creating chaincode: %v", err)
 	}